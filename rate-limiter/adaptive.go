@@ -0,0 +1,262 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AdaptiveConfig configures an AdaptiveLimiter. Rates are in actions per second.
+type AdaptiveConfig struct {
+	// MaxRate is the highest rate the limiter will climb to. Required; NewAdaptiveLimiter panics if it is not positive.
+	MaxRate float64
+	// MinRate is the lowest rate the limiter will back off to. Defaults to MaxRate/100.
+	MinRate float64
+	// InitialRate is the starting rate. Defaults to MaxRate.
+	InitialRate float64
+	// Burst is the number of actions that may happen at once. Defaults to 1.
+	Burst int
+	// IncreaseStep is added to the rate after every success. Defaults to MinRate.
+	IncreaseStep float64
+	// DecreaseFactor multiplies the rate after a throttle or slow response. Defaults to 0.5.
+	DecreaseFactor float64
+	// DecreaseCooldown ignores further decreases for this long after one, so a wave of
+	// throttled in-flight requests backs off once. Defaults to one second.
+	DecreaseCooldown time.Duration
+	// LatencyThreshold treats successes slower than this as a sign of overload. 0 disables it.
+	LatencyThreshold time.Duration
+}
+
+// AdaptiveLimiter is a token bucket whose rate follows the outcomes reported by its callers,
+// using additive increase and multiplicative decrease (AIMD) within configured bounds.
+// A throttled outcome carrying a Retry-After also pauses the limiter until then.
+type AdaptiveLimiter struct {
+	tb  *TokenBucket
+	cfg AdaptiveConfig
+
+	mu           sync.Mutex
+	clock        Clock
+	rate         float64
+	pausedUntil  time.Time
+	lastDecrease time.Time
+}
+
+// NewAdaptiveLimiter creates an adaptive limiter starting at cfg.InitialRate.
+// It panics if cfg.MaxRate is not positive, as the limiter would never refill.
+func NewAdaptiveLimiter(cfg AdaptiveConfig) *AdaptiveLimiter {
+	if cfg.MaxRate <= 0 {
+		panic("non-positive MaxRate for NewAdaptiveLimiter")
+	}
+	if cfg.MinRate <= 0 {
+		cfg.MinRate = cfg.MaxRate / 100
+	}
+	if cfg.InitialRate <= 0 {
+		cfg.InitialRate = cfg.MaxRate
+	}
+	if cfg.InitialRate < cfg.MinRate {
+		cfg.InitialRate = cfg.MinRate
+	}
+	if cfg.Burst <= 0 {
+		cfg.Burst = 1
+	}
+	if cfg.IncreaseStep <= 0 {
+		cfg.IncreaseStep = cfg.MinRate
+	}
+	if cfg.DecreaseFactor <= 0 || cfg.DecreaseFactor >= 1 {
+		cfg.DecreaseFactor = 0.5
+	}
+	if cfg.DecreaseCooldown <= 0 {
+		cfg.DecreaseCooldown = time.Second
+	}
+
+	tb := newTokenBucket(cfg.InitialRate, cfg.Burst)
+
+	return &AdaptiveLimiter{
+		tb:    tb,
+		cfg:   cfg,
+		clock: SystemClock,
+		rate:  cfg.InitialRate,
+	}
+}
+
+// Wait blocks until the limiter is no longer paused and a token is available, or the context is done.
+// Returns true if a token was acquired, false if context was canceled or the limiter was stopped.
+func (al *AdaptiveLimiter) Wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	return waitFor(ctx, al.Reserve(), al.tb.stopCh) == nil
+}
+
+// Allow reports whether an action may happen right now, consuming a token if so.
+// Always false while the limiter is paused by a Retry-After.
+func (al *AdaptiveLimiter) Allow() bool {
+	if al.now().Before(al.paused()) {
+		return false
+	}
+	return al.tb.Allow()
+}
+
+// Reserve claims a token and reports how long until it may be used, including any pause.
+func (al *AdaptiveLimiter) Reserve() *Reservation {
+	r := al.tb.Reserve()
+	if pausedUntil := al.paused(); r.ok && pausedUntil.After(r.timeToAct) {
+		r.timeToAct = pausedUntil
+	}
+	return r
+}
+
+// SetClock makes the limiter use c as its source of time, e.g. a FakeClock in tests.
+func (al *AdaptiveLimiter) SetClock(c Clock) {
+	al.mu.Lock()
+	al.clock = c
+	al.mu.Unlock()
+
+	al.tb.SetClock(c)
+}
+
+func (al *AdaptiveLimiter) currentClock() Clock {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	return al.clock
+}
+
+// Level returns the number of tokens held for later use and the burst size.
+func (al *AdaptiveLimiter) Level() (float64, int) {
+	return al.tb.Level()
+}
+
+// SetObserver makes the limiter report tokens dropped because it is full to o.
+func (al *AdaptiveLimiter) SetObserver(o Observer) {
+	al.tb.SetObserver(o)
+}
+
+// Stop stops the limiter. Blocked and future callers of Wait return immediately.
+func (al *AdaptiveLimiter) Stop() {
+	al.tb.Stop()
+}
+
+// Rate returns the current rate in actions per second.
+func (al *AdaptiveLimiter) Rate() float64 {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	return al.rate
+}
+
+// OnSuccess reports a successful action and how long it took.
+// The rate grows by IncreaseStep, unless the latency exceeds LatencyThreshold,
+// in which case the rate is decreased instead.
+func (al *AdaptiveLimiter) OnSuccess(latency time.Duration) {
+	if al.cfg.LatencyThreshold > 0 && latency > al.cfg.LatencyThreshold {
+		al.decrease(al.now())
+		return
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	al.setRate(al.rate + al.cfg.IncreaseStep)
+}
+
+// OnThrottled reports that the upstream rejected an action as over its limit (e.g. HTTP 429 or 503).
+// The rate is decreased, and a positive retryAfter pauses the limiter for that long.
+func (al *AdaptiveLimiter) OnThrottled(retryAfter time.Duration) {
+	now := al.now()
+	al.decrease(now)
+
+	if retryAfter <= 0 {
+		return
+	}
+
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if until := now.Add(retryAfter); until.After(al.pausedUntil) {
+		al.pausedUntil = until
+	}
+}
+
+// OnResponse reports the outcome of an HTTP call: 429 and 503 responses count as throttled,
+// honouring their Retry-After header, and anything else as a success taking latency.
+func (al *AdaptiveLimiter) OnResponse(resp *http.Response, latency time.Duration) {
+	if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+		retryAfter, _ := ParseRetryAfter(resp.Header.Get("Retry-After"), al.now())
+		al.OnThrottled(retryAfter)
+		return
+	}
+	al.OnSuccess(latency)
+}
+
+func (al *AdaptiveLimiter) decrease(now time.Time) {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	if now.Sub(al.lastDecrease) < al.cfg.DecreaseCooldown {
+		return
+	}
+	al.lastDecrease = now
+	al.setRate(al.rate * al.cfg.DecreaseFactor)
+}
+
+// setRate clamps rate to the configured bounds and applies it. Must be called with mu held.
+func (al *AdaptiveLimiter) setRate(rate float64) {
+	if rate > al.cfg.MaxRate {
+		rate = al.cfg.MaxRate
+	}
+	if rate < al.cfg.MinRate {
+		rate = al.cfg.MinRate
+	}
+	if rate == al.rate {
+		return
+	}
+
+	al.rate = rate
+	al.tb.setRate(rate)
+}
+
+func (al *AdaptiveLimiter) now() time.Time {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	return al.clock.Now()
+}
+
+func (al *AdaptiveLimiter) paused() time.Time {
+	al.mu.Lock()
+	defer al.mu.Unlock()
+
+	return al.pausedUntil
+}
+
+// ParseRetryAfter parses a Retry-After header value given either as a number
+// of seconds or as an HTTP date, returning how long to wait from now.
+func ParseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+
+	if secs, err := strconv.Atoi(value); err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	at, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if d := at.Sub(now); d > 0 {
+		return d, true
+	}
+	return 0, true
+}
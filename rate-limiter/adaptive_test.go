@@ -0,0 +1,179 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestAdaptiveLimiterAIMD(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        rlm.AdaptiveConfig
+		feed       func(al *rlm.AdaptiveLimiter)
+		expectRate float64
+	}{
+		{
+			name: "throttle halves the rate",
+			cfg:  rlm.AdaptiveConfig{MaxRate: 10, MinRate: 1},
+			feed: func(al *rlm.AdaptiveLimiter) {
+				al.OnThrottled(0)
+			},
+			expectRate: 5,
+		},
+		{
+			name: "throttles within the cooldown back off once",
+			cfg:  rlm.AdaptiveConfig{MaxRate: 10, MinRate: 1, DecreaseCooldown: time.Hour},
+			feed: func(al *rlm.AdaptiveLimiter) {
+				al.OnThrottled(0)
+				al.OnThrottled(0)
+				al.OnThrottled(0)
+			},
+			expectRate: 5,
+		},
+		{
+			name: "rate does not drop below the minimum",
+			cfg:  rlm.AdaptiveConfig{MaxRate: 10, MinRate: 4, DecreaseCooldown: time.Nanosecond},
+			feed: func(al *rlm.AdaptiveLimiter) {
+				for i := 0; i < 5; i++ {
+					al.OnThrottled(0)
+				}
+			},
+			expectRate: 4,
+		},
+		{
+			name: "successes increase the rate additively",
+			cfg:  rlm.AdaptiveConfig{MaxRate: 10, MinRate: 1, InitialRate: 2, IncreaseStep: 0.5},
+			feed: func(al *rlm.AdaptiveLimiter) {
+				for i := 0; i < 4; i++ {
+					al.OnSuccess(time.Millisecond)
+				}
+			},
+			expectRate: 4,
+		},
+		{
+			name: "rate does not exceed the maximum",
+			cfg:  rlm.AdaptiveConfig{MaxRate: 10, InitialRate: 9, IncreaseStep: 1},
+			feed: func(al *rlm.AdaptiveLimiter) {
+				for i := 0; i < 5; i++ {
+					al.OnSuccess(time.Millisecond)
+				}
+			},
+			expectRate: 10,
+		},
+		{
+			name: "slow responses decrease the rate",
+			cfg:  rlm.AdaptiveConfig{MaxRate: 10, MinRate: 1, LatencyThreshold: 100 * time.Millisecond},
+			feed: func(al *rlm.AdaptiveLimiter) {
+				al.OnSuccess(time.Second)
+			},
+			expectRate: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			al := rlm.NewAdaptiveLimiter(tt.cfg)
+			defer al.Stop()
+
+			tt.feed(al)
+			if got := al.Rate(); got != tt.expectRate {
+				t.Errorf("expected rate %v, got %v", tt.expectRate, got)
+			}
+		})
+	}
+}
+
+func TestAdaptiveLimiterRetryAfterPauses(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	al := rlm.NewAdaptiveLimiter(rlm.AdaptiveConfig{MaxRate: 1000, Burst: 10})
+	al.SetClock(clock)
+	defer al.Stop()
+
+	resp := &http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{"Retry-After": []string{"1"}},
+	}
+	al.OnResponse(resp, 0)
+
+	if al.Allow() {
+		t.Error("expected Allow to fail while paused")
+	}
+	r := al.Reserve()
+	if d := r.Delay(); d != time.Second {
+		t.Errorf("expected reservation to wait out the pause, got %s", d)
+	}
+	r.Cancel()
+
+	done := make(chan bool)
+	go func() {
+		done <- al.Wait(context.Background())
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if !<-done {
+		t.Error("expected Wait to proceed after the pause")
+	}
+	if !al.Allow() {
+		t.Error("expected Allow to succeed after the pause")
+	}
+}
+
+func TestAdaptiveLimiterRequiresMaxRate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic without MaxRate")
+		}
+	}()
+	rlm.NewAdaptiveLimiter(rlm.AdaptiveConfig{MinRate: 1})
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		value    string
+		expected time.Duration
+		ok       bool
+	}{
+		{
+			name:     "seconds",
+			value:    "120",
+			expected: 2 * time.Minute,
+			ok:       true,
+		},
+		{
+			name:     "http date",
+			value:    "Sun, 01 Jun 2025 12:00:30 GMT",
+			expected: 30 * time.Second,
+			ok:       true,
+		},
+		{
+			name:     "http date in the past",
+			value:    "Sun, 01 Jun 2025 11:00:00 GMT",
+			expected: 0,
+			ok:       true,
+		},
+		{
+			name:  "empty",
+			value: "",
+		},
+		{
+			name:  "garbage",
+			value: "soon",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := rlm.ParseRetryAfter(tt.value, now)
+			if got != tt.expected || ok != tt.ok {
+				t.Errorf("expected (%s, %v), got (%s, %v)", tt.expected, tt.ok, got, ok)
+			}
+		})
+	}
+}
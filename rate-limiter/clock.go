@@ -0,0 +1,201 @@
+package ratelimiter
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock is the source of time used by the limiters. The default is the system clock;
+// tests can use a FakeClock to control time by hand instead of sleeping.
+type Clock interface {
+	Now() time.Time
+	NewTimer(d time.Duration) Timer
+	NewTicker(d time.Duration) Ticker
+}
+
+// Timer is the part of time.Timer used by the limiters.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+}
+
+// Ticker is the part of time.Ticker used by the limiters.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// SystemClock is the Clock backed by the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTimer(d time.Duration) Timer { return systemTimer{time.NewTimer(d)} }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTimer struct{ *time.Timer }
+
+func (t systemTimer) C() <-chan time.Time { return t.Timer.C }
+
+type systemTicker struct{ *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// FakeClock is a Clock which only moves when Advance is called.
+// Timers and tickers fire as the clock passes their deadlines.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+// fakeWaiter is a timer (period 0) or ticker registered with a FakeClock.
+type fakeWaiter struct {
+	clock  *FakeClock
+	c      chan time.Time
+	at     time.Time
+	period time.Duration
+}
+
+var _ Clock = (*FakeClock)(nil)
+
+// NewFakeClock creates a fake clock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	fc := &FakeClock{now: now}
+	fc.cond = sync.NewCond(&fc.mu)
+	return fc
+}
+
+// Now returns the fake clock's current time.
+func (fc *FakeClock) Now() time.Time {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	return fc.now
+}
+
+// NewTimer creates a timer firing once the clock has been advanced by d.
+func (fc *FakeClock) NewTimer(d time.Duration) Timer {
+	return fc.register(d, 0)
+}
+
+// NewTicker creates a ticker firing every time the clock has been advanced by d.
+func (fc *FakeClock) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	return fakeTicker{fc.register(d, d)}
+}
+
+// Advance moves the clock forward by d, firing every timer and ticker whose deadline is passed
+// in deadline order. Like their time package counterparts, the channels hold a single pending
+// tick, so a ticker passed several times while its reader is busy delivers one tick.
+func (fc *FakeClock) Advance(d time.Duration) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	target := fc.now.Add(d)
+	for {
+		sort.SliceStable(fc.waiters, func(i, j int) bool {
+			return fc.waiters[i].at.Before(fc.waiters[j].at)
+		})
+		if len(fc.waiters) == 0 || fc.waiters[0].at.After(target) {
+			break
+		}
+
+		w := fc.waiters[0]
+		fc.now = w.at
+		select {
+		case w.c <- fc.now:
+		default:
+		}
+
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			fc.waiters = fc.waiters[1:]
+		}
+	}
+	fc.now = target
+	fc.cond.Broadcast()
+}
+
+// BlockUntil blocks until at least n timers and tickers are waiting on the clock.
+// Tests use it to make sure a goroutine has started waiting before advancing the clock.
+func (fc *FakeClock) BlockUntil(n int) {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for len(fc.waiters) < n {
+		fc.cond.Wait()
+	}
+}
+
+func (fc *FakeClock) register(d, period time.Duration) *fakeWaiter {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	w := &fakeWaiter{
+		clock:  fc,
+		c:      make(chan time.Time, 1),
+		at:     fc.now.Add(d),
+		period: period,
+	}
+	if d <= 0 {
+		w.c <- fc.now
+		return w
+	}
+
+	fc.waiters = append(fc.waiters, w)
+	fc.cond.Broadcast()
+	return w
+}
+
+func (fc *FakeClock) remove(w *fakeWaiter) bool {
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	for i, other := range fc.waiters {
+		if other == w {
+			fc.waiters = append(fc.waiters[:i], fc.waiters[i+1:]...)
+			fc.cond.Broadcast()
+			return true
+		}
+	}
+	return false
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.c
+}
+
+func (w *fakeWaiter) Stop() bool {
+	return w.clock.remove(w)
+}
+
+// fakeTicker adapts a fakeWaiter to the Ticker interface.
+type fakeTicker struct{ *fakeWaiter }
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	w := t.fakeWaiter
+	fc := w.clock
+	fc.remove(w)
+
+	fc.mu.Lock()
+	defer fc.mu.Unlock()
+
+	w.at = fc.now.Add(d)
+	w.period = d
+	fc.waiters = append(fc.waiters, w)
+	fc.cond.Broadcast()
+}
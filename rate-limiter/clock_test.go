@@ -0,0 +1,87 @@
+package ratelimiter_test
+
+import (
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestFakeClockTimer(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	timer := clock.NewTimer(time.Second)
+
+	clock.Advance(999 * time.Millisecond)
+	select {
+	case <-timer.C():
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	clock.Advance(time.Millisecond)
+	select {
+	case at := <-timer.C():
+		if !at.Equal(epoch.Add(time.Second)) {
+			t.Errorf("expected timer to fire at %s, got %s", epoch.Add(time.Second), at)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+
+	if timer.Stop() {
+		t.Error("expected Stop to report the timer had already fired")
+	}
+}
+
+func TestFakeClockTicker(t *testing.T) {
+	tests := []struct {
+		name        string
+		interval    time.Duration
+		advance     time.Duration
+		expectTicks int
+	}{
+		{
+			name:        "half an interval per advance",
+			interval:    time.Second,
+			advance:     500 * time.Millisecond,
+			expectTicks: 2,
+		},
+		{
+			name:        "one tick per advance",
+			interval:    time.Second,
+			advance:     time.Second,
+			expectTicks: 5,
+		},
+		{
+			name:        "ticks are dropped while the reader is busy",
+			interval:    time.Second,
+			advance:     3 * time.Second,
+			expectTicks: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := rlm.NewFakeClock(epoch)
+			ticker := clock.NewTicker(tt.interval)
+			defer ticker.Stop()
+
+			ticks := 0
+			for i := 0; i < 5; i++ {
+				clock.Advance(tt.advance)
+				select {
+				case <-ticker.C():
+					ticks++
+				default:
+				}
+			}
+
+			if ticks != tt.expectTicks {
+				t.Errorf("expected %d ticks, got %d", tt.expectTicks, ticks)
+			}
+			if got, expected := clock.Now(), epoch.Add(5*tt.advance); !got.Equal(expected) {
+				t.Errorf("expected clock at %s, got %s", expected, got)
+			}
+		})
+	}
+}
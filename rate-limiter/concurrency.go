@@ -0,0 +1,268 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrQueueFull is returned when a caller cannot even queue for a slot because the queue is full.
+	ErrQueueFull = errors.New("concurrency limiter queue is full")
+	// ErrQueueTimeout is returned when a caller gives up after waiting for a slot for the queue timeout.
+	ErrQueueTimeout = errors.New("timed out waiting for a concurrency slot")
+)
+
+// ConcurrencyLimiter caps the number of actions in flight at once, like a semaphore.
+// Callers over the limit wait in a bounded FIFO queue, for at most the queue timeout.
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	clock    Clock
+	limit    int
+	maxQueue int
+	timeout  time.Duration
+	inFlight int
+	queue    *list.List // of *concurrencyWaiter, oldest at the front
+
+	rejected uint64
+	timedOut uint64
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+type concurrencyWaiter struct {
+	ready chan struct{} // closed when a slot is handed to the waiter
+}
+
+// ConcurrencyStats is a snapshot of a ConcurrencyLimiter.
+type ConcurrencyStats struct {
+	// Limit is the maximum number of actions in flight.
+	Limit int
+	// InFlight is the number of slots currently held.
+	InFlight int
+	// Queued is the number of callers waiting for a slot.
+	Queued int
+	// Rejected counts callers turned away because the queue was full.
+	Rejected uint64
+	// TimedOut counts callers which gave up after the queue timeout.
+	TimedOut uint64
+}
+
+// NewConcurrencyLimiter creates a limiter allowing `limit` actions in flight at once.
+// maxQueue caps the number of callers waiting for a slot; 0 means no cap.
+// timeout bounds how long a caller waits for a slot; 0 means no bound.
+func NewConcurrencyLimiter(limit, maxQueue int, timeout time.Duration) *ConcurrencyLimiter {
+	if limit < 1 {
+		limit = 1
+	}
+
+	return &ConcurrencyLimiter{
+		clock:    SystemClock,
+		limit:    limit,
+		maxQueue: maxQueue,
+		timeout:  timeout,
+		queue:    list.New(),
+		stopCh:   make(chan struct{}),
+	}
+}
+
+// Acquire blocks until a slot is free, then returns a function releasing it.
+// The release function must be called once the action is done; calling it again has no effect.
+// Returns ErrQueueFull if the queue is full, ErrQueueTimeout if no slot was free within
+// the queue timeout, ErrLimiterStopped if the limiter was stopped, or the context error.
+func (cl *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-cl.stopCh:
+		return nil, ErrLimiterStopped
+	default:
+	}
+
+	cl.mu.Lock()
+	if cl.inFlight < cl.limit && cl.queue.Len() == 0 {
+		cl.inFlight++
+		cl.mu.Unlock()
+		return cl.releaseFunc(), nil
+	}
+	if cl.maxQueue > 0 && cl.queue.Len() >= cl.maxQueue {
+		cl.rejected++
+		cl.mu.Unlock()
+		return nil, ErrQueueFull
+	}
+
+	w := &concurrencyWaiter{ready: make(chan struct{})}
+	el := cl.queue.PushBack(w)
+	clock := cl.clock
+	cl.mu.Unlock()
+
+	var timeoutCh <-chan time.Time
+	if cl.timeout > 0 {
+		timer := clock.NewTimer(cl.timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C()
+	}
+
+	var err error
+	select {
+	case <-w.ready:
+		return cl.releaseFunc(), nil
+	case <-timeoutCh:
+		err = ErrQueueTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	case <-cl.stopCh:
+		err = ErrLimiterStopped
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	select {
+	case <-w.ready:
+		// a slot was handed over while giving up; pass it on
+		cl.release()
+		return nil, err
+	default:
+	}
+
+	cl.queue.Remove(el)
+	if err == ErrQueueTimeout {
+		cl.timedOut++
+	}
+	return nil, err
+}
+
+// TryAcquire takes a slot if one is free right now, without queueing.
+// Returns the function releasing the slot, or false if none was free.
+func (cl *ConcurrencyLimiter) TryAcquire() (func(), bool) {
+	select {
+	case <-cl.stopCh:
+		return nil, false
+	default:
+	}
+
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	if cl.inFlight >= cl.limit || cl.queue.Len() > 0 {
+		return nil, false
+	}
+	cl.inFlight++
+	return cl.releaseFunc(), true
+}
+
+// Stats returns the current number of slots in use and callers queued,
+// along with how many callers were turned away so far.
+func (cl *ConcurrencyLimiter) Stats() ConcurrencyStats {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	return ConcurrencyStats{
+		Limit:    cl.limit,
+		InFlight: cl.inFlight,
+		Queued:   cl.queue.Len(),
+		Rejected: cl.rejected,
+		TimedOut: cl.timedOut,
+	}
+}
+
+// SetClock makes queue timeouts use c as their source of time, e.g. a FakeClock in tests.
+func (cl *ConcurrencyLimiter) SetClock(c Clock) {
+	cl.mu.Lock()
+	defer cl.mu.Unlock()
+
+	cl.clock = c
+}
+
+// Stop stops the limiter. Queued and future callers of Acquire return ErrLimiterStopped;
+// slots already held stay valid until released.
+func (cl *ConcurrencyLimiter) Stop() {
+	cl.stopOnce.Do(func() {
+		close(cl.stopCh)
+	})
+}
+
+func (cl *ConcurrencyLimiter) releaseFunc() func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			cl.mu.Lock()
+			defer cl.mu.Unlock()
+
+			cl.release()
+		})
+	}
+}
+
+// release frees a slot, handing it straight to the oldest waiter if there is one.
+// Must be called with mu held.
+func (cl *ConcurrencyLimiter) release() {
+	if el := cl.queue.Front(); el != nil {
+		cl.queue.Remove(el)
+		close(el.Value.(*concurrencyWaiter).ready)
+		return
+	}
+	cl.inFlight--
+}
+
+// Policy combines a concurrency limit with a rate limit, e.g. "at most 5 in flight and 10/sec".
+// Either part may be nil.
+type Policy struct {
+	Concurrency *ConcurrencyLimiter
+	Rate        Limiter
+}
+
+// NewPolicy creates a policy allowing `inFlight` actions at once, queueing at most
+// `maxQueue` callers for `timeout`, and admitting actions at the pace of rate.
+func NewPolicy(inFlight, maxQueue int, timeout time.Duration, rate Limiter) *Policy {
+	return &Policy{
+		Concurrency: NewConcurrencyLimiter(inFlight, maxQueue, timeout),
+		Rate:        rate,
+	}
+}
+
+// Acquire waits for a concurrency slot and then for the rate limiter, returning a function
+// releasing the slot once the action is done. The slot is freed again if the rate limiter
+// does not admit the action.
+func (p *Policy) Acquire(ctx context.Context) (func(), error) {
+	release := func() {}
+	if p.Concurrency != nil {
+		var err error
+		if release, err = p.Concurrency.Acquire(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	if p.Rate != nil {
+		if err := waitN(ctx, p.Rate, 1); err != nil {
+			release()
+			return nil, err
+		}
+	}
+	return release, nil
+}
+
+// Do runs fn once the policy admits it, holding a concurrency slot while it runs.
+func (p *Policy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	release, err := p.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	return fn(ctx)
+}
+
+// Stop stops both limiters.
+func (p *Policy) Stop() {
+	if p.Concurrency != nil {
+		p.Concurrency.Stop()
+	}
+	if p.Rate != nil {
+		p.Rate.Stop()
+	}
+}
@@ -0,0 +1,106 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestConcurrencyLimiterQueue(t *testing.T) {
+	cl := rlm.NewConcurrencyLimiter(1, 1, 0)
+	defer cl.Stop()
+
+	release, err := cl.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, ok := cl.TryAcquire(); ok {
+		t.Fatal("expected no free slot")
+	}
+
+	acquired := make(chan func())
+	go func() {
+		r, err := cl.Acquire(waitCtx(t))
+		if err != nil {
+			t.Errorf("unexpected error: %v", err)
+		}
+		acquired <- r
+	}()
+
+	for cl.Stats().Queued != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := cl.Acquire(context.Background()); !errors.Is(err, rlm.ErrQueueFull) {
+		t.Errorf("expected ErrQueueFull, got %v", err)
+	}
+
+	release()
+	release() // releasing twice must not free a second slot
+	next := <-acquired
+
+	expected := rlm.ConcurrencyStats{Limit: 1, InFlight: 1, Rejected: 1}
+	if got := cl.Stats(); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	next()
+	if got := cl.Stats().InFlight; got != 0 {
+		t.Errorf("expected no slot in use, got %d", got)
+	}
+}
+
+func TestConcurrencyLimiterTimeout(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	cl := rlm.NewConcurrencyLimiter(1, 0, time.Second)
+	cl.SetClock(clock)
+	defer cl.Stop()
+
+	if _, err := cl.Acquire(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := cl.Acquire(context.Background())
+		done <- err
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if err := <-done; !errors.Is(err, rlm.ErrQueueTimeout) {
+		t.Errorf("expected ErrQueueTimeout, got %v", err)
+	}
+
+	stats := cl.Stats()
+	if stats.Queued != 0 || stats.TimedOut != 1 {
+		t.Errorf("expected the timed out caller to leave the queue, got %+v", stats)
+	}
+}
+
+func TestPolicyReleasesSlotWhenRateLimited(t *testing.T) {
+	tb, _ := newFakeTokenBucket(1, time.Hour, 1)
+	p := rlm.NewPolicy(5, 0, 0, tb)
+	defer p.Stop()
+
+	err := p.Do(context.Background(), func(ctx context.Context) error {
+		if got := p.Concurrency.Stats().InFlight; got != 1 {
+			t.Errorf("expected 1 slot in use while running, got %d", got)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.Acquire(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the rate limit to hold the caller back, got %v", err)
+	}
+	if got := p.Concurrency.Stats().InFlight; got != 0 {
+		t.Errorf("expected the slot to be released, got %d in flight", got)
+	}
+}
@@ -0,0 +1,203 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DistributedLimiter allows approximately `limit` actions in any rolling window,
+// counting them in a Store so that every process sharing the store and key
+// draws from the same quota. It uses the same estimate as SlidingWindowCounter.
+type DistributedLimiter struct {
+	store  Store
+	key    string
+	limit  int
+	window time.Duration
+
+	mu       sync.Mutex
+	clock    Clock
+	failOpen bool
+	lastErr  error
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewDistributedLimiter creates a limiter allowing `limit` actions per rolling `window`
+// across all processes using the same store and key, e.g. "scraper:example.com".
+func NewDistributedLimiter(store Store, key string, limit int, window time.Duration) *DistributedLimiter {
+	return &DistributedLimiter{
+		store:  store,
+		key:    key,
+		limit:  limit,
+		window: window,
+		clock:  SystemClock,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// SetClock makes the limiter use c as its source of time, e.g. a FakeClock in tests.
+func (dl *DistributedLimiter) SetClock(c Clock) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.clock = c
+}
+
+// SetFailOpen controls what happens when the store cannot be reached.
+// By default actions are rejected; with failOpen they are admitted instead.
+func (dl *DistributedLimiter) SetFailOpen(failOpen bool) {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.failOpen = failOpen
+}
+
+// Err returns the last error returned by the store, if any.
+func (dl *DistributedLimiter) Err() error {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	return dl.lastErr
+}
+
+// Wait blocks until the shared quota has room or the context is done.
+// Returns true if an action was admitted, false if context was canceled or the limiter was stopped.
+func (dl *DistributedLimiter) Wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	return waitFor(ctx, dl.reserve(ctx), dl.stopCh) == nil
+}
+
+// Allow reports whether the shared quota has room right now, counting the action if so.
+func (dl *DistributedLimiter) Allow() bool {
+	if dl.limit <= 0 || dl.window <= 0 || dl.stopped() {
+		return false
+	}
+
+	ctx := context.Background()
+	now := dl.now()
+	idx := windowIndex(now, dl.window)
+
+	at, err := dl.claim(ctx, idx, now)
+	if err != nil {
+		return dl.onError(err)
+	}
+	if !at.Equal(now) {
+		if !at.IsZero() {
+			dl.release(idx)
+		}
+		return false
+	}
+	return true
+}
+
+// Reserve counts an action at the earliest time the shared quota has room
+// and reports how long until then.
+func (dl *DistributedLimiter) Reserve() *Reservation {
+	return dl.reserve(context.Background())
+}
+
+// Stop stops the limiter. Blocked and future callers of Wait return immediately.
+// The shared counters are left for other processes.
+func (dl *DistributedLimiter) Stop() {
+	dl.stopOnce.Do(func() {
+		close(dl.stopCh)
+	})
+}
+
+func (dl *DistributedLimiter) stopped() bool {
+	select {
+	case <-dl.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (dl *DistributedLimiter) reserve(ctx context.Context) *Reservation {
+	if dl.limit <= 0 || dl.window <= 0 || dl.stopped() {
+		return &Reservation{}
+	}
+
+	now := dl.now()
+	for w := windowIndex(now, dl.window); ; w++ {
+		at, err := dl.claim(ctx, w, now)
+		if err != nil {
+			if dl.onError(err) {
+				return &Reservation{ok: true, clock: dl.currentClock(), timeToAct: now}
+			}
+			return &Reservation{}
+		}
+		if !at.IsZero() {
+			return &Reservation{
+				ok:        true,
+				clock:     dl.currentClock(),
+				timeToAct: at,
+				cancel:    func() { dl.release(w) },
+			}
+		}
+	}
+}
+
+// claim counts an action in window w and returns the earliest time it may happen.
+// If window w has no room the count is undone and the zero time is returned.
+func (dl *DistributedLimiter) claim(ctx context.Context, w int64, now time.Time) (time.Time, error) {
+	curr, err := dl.store.IncrBy(ctx, dl.windowKey(w), 1, dl.ttl())
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	prev, err := dl.store.Get(ctx, dl.windowKey(w-1))
+	if err != nil {
+		dl.release(w)
+		return time.Time{}, err
+	}
+
+	at := earliestInWindow(windowStart(w, dl.window), dl.window, dl.limit, int(prev), int(curr-1), now)
+	if at.IsZero() {
+		dl.release(w)
+	}
+	return at, nil
+}
+
+func (dl *DistributedLimiter) release(w int64) {
+	if _, err := dl.store.IncrBy(context.Background(), dl.windowKey(w), -1, dl.ttl()); err != nil {
+		dl.onError(err)
+	}
+}
+
+// onError records a store error and reports whether the action should be admitted anyway.
+func (dl *DistributedLimiter) onError(err error) bool {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	dl.lastErr = err
+	return dl.failOpen
+}
+
+func (dl *DistributedLimiter) currentClock() Clock {
+	dl.mu.Lock()
+	defer dl.mu.Unlock()
+
+	return dl.clock
+}
+
+func (dl *DistributedLimiter) now() time.Time {
+	return dl.currentClock().Now()
+}
+
+func (dl *DistributedLimiter) windowKey(w int64) string {
+	return fmt.Sprintf("%s:%d", dl.key, w)
+}
+
+// ttl keeps a window's counter around while it still serves as the previous window.
+func (dl *DistributedLimiter) ttl() time.Duration {
+	return 2*dl.window + time.Second
+}
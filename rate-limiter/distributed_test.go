@@ -0,0 +1,147 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestDistributedLimiterSharedQuota(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		replicas    int
+		attempts    int
+		expectCount int
+	}{
+		{
+			name:        "single replica",
+			limit:       5,
+			replicas:    1,
+			attempts:    8,
+			expectCount: 5,
+		},
+		{
+			name:        "three replicas share one quota",
+			limit:       5,
+			replicas:    3,
+			attempts:    4,
+			expectCount: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := rlm.NewMemoryStore()
+
+			count := 0
+			for r := 0; r < tt.replicas; r++ {
+				dl := rlm.NewDistributedLimiter(store, "test", tt.limit, time.Hour)
+				defer dl.Stop()
+
+				for i := 0; i < tt.attempts; i++ {
+					if dl.Allow() {
+						count++
+					}
+				}
+			}
+
+			if count != tt.expectCount {
+				t.Errorf("expected %d allowed actions, got %d", tt.expectCount, count)
+			}
+		})
+	}
+}
+
+func TestDistributedLimiterReserve(t *testing.T) {
+	store := rlm.NewMemoryStore()
+	a := rlm.NewDistributedLimiter(store, "test", 1, time.Hour)
+	b := rlm.NewDistributedLimiter(store, "test", 1, time.Hour)
+	defer a.Stop()
+	defer b.Stop()
+
+	if r := a.Reserve(); !r.OK() || r.Delay() != 0 {
+		t.Fatalf("expected immediate reservation, got ok=%v delay=%s", r.OK(), r.Delay())
+	}
+
+	r := b.Reserve()
+	if !r.OK() || r.Delay() == 0 {
+		t.Fatalf("expected delayed reservation, got ok=%v delay=%s", r.OK(), r.Delay())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if b.Wait(ctx) {
+		t.Error("expected Wait to time out while the quota is used up")
+	}
+}
+
+type failingStore struct{}
+
+func (failingStore) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	return 0, errors.New("store unavailable")
+}
+
+func (failingStore) Get(ctx context.Context, key string) (int64, error) {
+	return 0, errors.New("store unavailable")
+}
+
+func TestDistributedLimiterStoreErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		failOpen    bool
+		expectAllow bool
+	}{
+		{
+			name:        "fail closed",
+			failOpen:    false,
+			expectAllow: false,
+		},
+		{
+			name:        "fail open",
+			failOpen:    true,
+			expectAllow: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dl := rlm.NewDistributedLimiter(failingStore{}, "test", 5, time.Second)
+			defer dl.Stop()
+			dl.SetFailOpen(tt.failOpen)
+
+			if got := dl.Allow(); got != tt.expectAllow {
+				t.Errorf("expected Allow to return %v, got %v", tt.expectAllow, got)
+			}
+			if got := dl.Reserve().OK(); got != tt.expectAllow {
+				t.Errorf("expected reservation OK to be %v, got %v", tt.expectAllow, got)
+			}
+			if dl.Err() == nil {
+				t.Error("expected store error to be recorded")
+			}
+		})
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	store := rlm.NewMemoryStore()
+	store.SetClock(clock)
+
+	ctx := context.Background()
+	store.IncrBy(ctx, "k", 2, time.Minute)
+
+	clock.Advance(59 * time.Second)
+	if v, _ := store.IncrBy(ctx, "k", 1, time.Minute); v != 3 {
+		t.Errorf("expected the counter to live until its ttl, got %d", v)
+	}
+
+	// the ttl runs from when the counter was created, not from its last change
+	clock.Advance(time.Second)
+	if v, _ := store.Get(ctx, "k"); v != 0 {
+		t.Errorf("expected the counter to expire after its ttl, got %d", v)
+	}
+}
@@ -0,0 +1,144 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// FixedWindow allows `limit` actions in each fixed window of time.
+// Windows are aligned to the Unix epoch, so a window of time.Minute matches
+// servers which count requests per calendar minute.
+type FixedWindow struct {
+	mu     sync.Mutex
+	clock  Clock
+	limit  int
+	window time.Duration
+	counts map[int64]int // actions counted per window index, including reserved future windows
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewFixedWindow creates a limiter allowing `limit` actions per `window`.
+func NewFixedWindow(limit int, window time.Duration) *FixedWindow {
+	return &FixedWindow{
+		clock:  SystemClock,
+		limit:  limit,
+		window: window,
+		counts: make(map[int64]int),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Wait blocks until the current or a later window has room or the context is done.
+// Returns true if an action was admitted, false if context was canceled or the limiter was stopped.
+func (fw *FixedWindow) Wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	return waitFor(ctx, fw.Reserve(), fw.stopCh) == nil
+}
+
+// Allow reports whether the current window has room, counting the action if so.
+func (fw *FixedWindow) Allow() bool {
+	if fw.limit <= 0 || fw.window <= 0 || fw.stopped() {
+		return false
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	idx := fw.prune(fw.clock.Now())
+	if fw.counts[idx] >= fw.limit {
+		return false
+	}
+	fw.counts[idx]++
+	return true
+}
+
+// Reserve claims a slot in the first window with room and reports how long until that window starts.
+func (fw *FixedWindow) Reserve() *Reservation {
+	if fw.limit <= 0 || fw.window <= 0 || fw.stopped() {
+		return &Reservation{}
+	}
+
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	now := fw.clock.Now()
+	idx := fw.prune(now)
+
+	w := idx
+	for fw.counts[w] >= fw.limit {
+		w++
+	}
+	fw.counts[w]++
+
+	timeToAct := now
+	if w > idx {
+		timeToAct = windowStart(w, fw.window)
+	}
+
+	return &Reservation{
+		ok:        true,
+		clock:     fw.clock,
+		timeToAct: timeToAct,
+		cancel:    func() { fw.release(w) },
+	}
+}
+
+// SetClock makes the limiter use c as its source of time, e.g. a FakeClock in tests.
+func (fw *FixedWindow) SetClock(c Clock) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	fw.clock = c
+}
+
+func (fw *FixedWindow) currentClock() Clock {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	return fw.clock
+}
+
+// Stop stops the limiter. Blocked and future callers of Wait return immediately.
+func (fw *FixedWindow) Stop() {
+	fw.stopOnce.Do(func() {
+		close(fw.stopCh)
+	})
+}
+
+func (fw *FixedWindow) stopped() bool {
+	select {
+	case <-fw.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// prune drops the counts of past windows and returns the current window index.
+// Must be called with mu held.
+func (fw *FixedWindow) prune(now time.Time) int64 {
+	idx := windowIndex(now, fw.window)
+	for w := range fw.counts {
+		if w < idx {
+			delete(fw.counts, w)
+		}
+	}
+	return idx
+}
+
+func (fw *FixedWindow) release(w int64) {
+	fw.mu.Lock()
+	defer fw.mu.Unlock()
+
+	if fw.counts[w] > 0 {
+		fw.counts[w]--
+	}
+}
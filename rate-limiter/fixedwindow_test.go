@@ -0,0 +1,114 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestFixedWindowAllow(t *testing.T) {
+	tests := []struct {
+		name        string
+		limit       int
+		attempts    int
+		expectCount int
+	}{
+		{
+			name:        "under the limit",
+			limit:       5,
+			attempts:    3,
+			expectCount: 3,
+		},
+		{
+			name:        "over the limit",
+			limit:       5,
+			attempts:    8,
+			expectCount: 5,
+		},
+		{
+			name:        "zero limit",
+			limit:       0,
+			attempts:    3,
+			expectCount: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fw := rlm.NewFixedWindow(tt.limit, time.Minute)
+			fw.SetClock(rlm.NewFakeClock(epoch))
+			defer fw.Stop()
+
+			count := 0
+			for i := 0; i < tt.attempts; i++ {
+				if fw.Allow() {
+					count++
+				}
+			}
+
+			if count != tt.expectCount {
+				t.Errorf("expected %d allowed actions, got %d", tt.expectCount, count)
+			}
+		})
+	}
+}
+
+func TestFixedWindowReserveNextWindow(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch.Add(45 * time.Second))
+	fw := rlm.NewFixedWindow(1, time.Minute)
+	fw.SetClock(clock)
+	defer fw.Stop()
+
+	if !fw.Allow() {
+		t.Fatal("expected first action to be allowed")
+	}
+
+	r := fw.Reserve()
+	if !r.OK() {
+		t.Fatal("expected reservation to be OK")
+	}
+	if d := r.Delay(); d != 15*time.Second {
+		t.Errorf("expected delay until the next calendar minute, got %s", d)
+	}
+
+	// canceling gives the slot in the next window back
+	r.Cancel()
+	if d := fw.Reserve().Delay(); d != 15*time.Second {
+		t.Errorf("expected canceled slot to be reused, got delay %s", d)
+	}
+
+	clock.Advance(15 * time.Second)
+	if fw.Allow() {
+		t.Error("expected the next window to be used up by the reservation")
+	}
+}
+
+func TestFixedWindowWaitStops(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	fw := rlm.NewFixedWindow(1, time.Hour)
+	fw.SetClock(clock)
+	fw.Wait(context.Background())
+
+	done := make(chan bool)
+	go func() {
+		done <- fw.Wait(context.Background())
+	}()
+
+	clock.BlockUntil(1)
+	fw.Stop()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("expected Wait to fail after Stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Stop")
+	}
+
+	if fw.Reserve().OK() {
+		t.Error("expected reservations to fail after Stop")
+	}
+}
@@ -0,0 +1,124 @@
+package ratelimiter
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// responseObserver is implemented by limiters which adapt to the responses they see, like AdaptiveLimiter.
+type responseObserver interface {
+	OnResponse(resp *http.Response, latency time.Duration)
+}
+
+var _ responseObserver = (*AdaptiveLimiter)(nil)
+
+// Transport is an http.RoundTripper which waits for a limiter before sending each request,
+// so any http.Client can be rate limited by setting its Transport.
+// Limiters which adapt to responses, like AdaptiveLimiter, are told about every response.
+type Transport struct {
+	// Base sends the requests. Defaults to http.DefaultTransport.
+	Base http.RoundTripper
+	// Limiter applies to every request. May be nil if Hosts is set.
+	Limiter Limiter
+	// Hosts, if set, applies a separate limiter per request host in addition to Limiter.
+	Hosts *KeyedLimiter
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewTransport creates a transport sending requests through base at the pace of l.
+// A nil base uses http.DefaultTransport.
+func NewTransport(base http.RoundTripper, l Limiter) *Transport {
+	return &Transport{
+		Base:    base,
+		Limiter: l,
+	}
+}
+
+// RoundTrip waits for the limiters and then sends req. If the request's context is done
+// first, or a limiter is stopped, the request is not sent and the error is returned.
+// The host's limiter is kept from eviction while the request waits for it.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if t.Limiter != nil {
+		if err := waitN(ctx, t.Limiter, 1); err != nil {
+			return nil, err
+		}
+	}
+	if t.Hosts != nil && !t.Hosts.Wait(ctx, req.URL.Host) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return nil, ErrLimiterStopped
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+
+	start := time.Now()
+	resp, err := base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	latency := time.Since(start)
+	if ro, ok := t.Limiter.(responseObserver); ok {
+		ro.OnResponse(resp, latency)
+	}
+	if t.Hosts != nil {
+		if ro, ok := t.Hosts.Get(req.URL.Host).(responseObserver); ok {
+			ro.OnResponse(resp, latency)
+		}
+	}
+	return resp, nil
+}
+
+// Middleware returns a handler which serves requests with next while l allows them,
+// and answers the rest with 429 Too Many Requests and a Retry-After header.
+func Middleware(l Limiter, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveLimited(w, r, l.Reserve(), next)
+	})
+}
+
+// KeyedMiddleware is like Middleware but applies the limiter of kl for the key of each request,
+// e.g. RemoteHost to limit every client separately.
+func KeyedMiddleware(kl *KeyedLimiter, key func(r *http.Request) string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		serveLimited(w, r, kl.Reserve(key(r)), next)
+	})
+}
+
+// RemoteHost returns the host part of the request's remote address, for use with KeyedMiddleware.
+// It does not look at forwarding headers, which clients can set freely.
+func RemoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// serveLimited serves r with next if res admits it right away, and rejects it otherwise.
+func serveLimited(w http.ResponseWriter, r *http.Request, res *Reservation, next http.Handler) {
+	if res.OK() && res.Delay() == 0 {
+		next.ServeHTTP(w, r)
+		return
+	}
+
+	if res.OK() {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(res.Delay())))
+		res.Cancel()
+	}
+	http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// retryAfterSeconds rounds d up to whole seconds, as Retry-After has no finer resolution.
+func retryAfterSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
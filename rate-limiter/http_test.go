@@ -0,0 +1,160 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestTransportWaitsForLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tb, _ := newFakeTokenBucket(1, time.Hour, 1)
+	defer tb.Stop()
+	client := &http.Client{Transport: rlm.NewTransport(nil, tb)}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	if _, err := client.Do(req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the second request to wait for a token, got %v", err)
+	}
+}
+
+func TestTransportReportsToAdaptiveLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	kl := rlm.NewKeyedLimiter(func() rlm.Limiter {
+		return rlm.NewAdaptiveLimiter(rlm.AdaptiveConfig{MaxRate: 10, MinRate: 1})
+	}, 0, 0)
+	defer kl.Stop()
+	client := &http.Client{Transport: &rlm.Transport{Hosts: kl}}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp.Body.Close()
+
+	al := kl.Get(resp.Request.URL.Host).(*rlm.AdaptiveLimiter)
+	if rate := al.Rate(); rate != 5 {
+		t.Errorf("expected the 429 to halve the host's rate to 5, got %v", rate)
+	}
+}
+
+func TestTransportKeepsWaitingHostFromEviction(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	// the same server reached as localhost is another host
+	otherURL := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	clock := rlm.NewFakeClock(epoch)
+	kl := rlm.NewKeyedLimiter(func() rlm.Limiter {
+		tb := rlm.NewTokenBucket(1, time.Second, 1)
+		tb.SetClock(clock)
+		return tb
+	}, 1, 0)
+	defer kl.Stop()
+	client := &http.Client{Transport: &rlm.Transport{Hosts: kl}}
+
+	get := func(url string) error {
+		resp, err := client.Get(url)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	if err := get(srv.URL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	errs := make(chan error, 3)
+	for range 3 {
+		go func() { errs <- get(srv.URL) }()
+	}
+	clock.BlockUntil(3)
+
+	// only one host is tracked, but the one being waited for must not be evicted for another
+	if err := get(otherURL); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Advance(3 * time.Second)
+
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Errorf("expected the waiting requests to be sent, got %v", err)
+		}
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	tb, _ := newFakeTokenBucket(1, 10*time.Second, 1)
+	defer tb.Stop()
+
+	h := rlm.Middleware(tb, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the first request to be served, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "10" {
+		t.Errorf("expected Retry-After of 10 seconds, got %q", got)
+	}
+
+	// the rejected request must not have used up the next token
+	if r := tb.Reserve(); r.Delay() != 10*time.Second {
+		t.Errorf("expected the next token in 10s, got %s", r.Delay())
+	}
+}
+
+func TestKeyedMiddleware(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	kl := rlm.NewKeyedLimiter(func() rlm.Limiter {
+		fw := rlm.NewFixedWindow(1, time.Hour)
+		fw.SetClock(clock)
+		return fw
+	}, 0, 0)
+	defer kl.Stop()
+
+	h := rlm.KeyedMiddleware(kl, rlm.RemoteHost, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, tt := range []struct {
+		remoteAddr string
+		expectCode int
+	}{
+		{remoteAddr: "10.0.0.1:1234", expectCode: http.StatusOK},
+		{remoteAddr: "10.0.0.1:5678", expectCode: http.StatusTooManyRequests},
+		{remoteAddr: "10.0.0.2:1234", expectCode: http.StatusOK},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = tt.remoteAddr
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != tt.expectCode {
+			t.Errorf("%s: expected %d, got %d", tt.remoteAddr, tt.expectCode, rec.Code)
+		}
+	}
+}
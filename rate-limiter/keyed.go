@@ -0,0 +1,239 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// KeyedLimiter keeps a separate limiter per key (e.g. host, API token or tenant),
+// creating them on first use. Keys which have not been used for a while, or which
+// exceed the maximum number of tracked keys, are evicted and their limiters stopped.
+// A key is never evicted while a caller of Wait, Allow or Reserve is using its limiter.
+type KeyedLimiter struct {
+	mu         sync.Mutex
+	clock      Clock
+	newLimiter func() Limiter
+	overrides  map[string]func() Limiter
+	maxKeys    int
+	idleTTL    time.Duration
+
+	entries map[string]*list.Element
+	lru     *list.List // most recently used at the front
+}
+
+type keyedEntry struct {
+	key      string
+	limiter  Limiter
+	lastUsed time.Time
+	inUse    int  // callers currently using the limiter
+	removed  bool // forgotten while in use, so the last caller stops the limiter
+}
+
+// NewKeyedLimiter creates a keyed limiter which uses newLimiter to create the limiter for a key.
+// maxKeys caps the number of tracked keys, evicting the least recently used one; 0 means no cap.
+// idleTTL evicts keys unused for that long; 0 means keys never expire.
+func NewKeyedLimiter(newLimiter func() Limiter, maxKeys int, idleTTL time.Duration) *KeyedLimiter {
+	return &KeyedLimiter{
+		clock:      SystemClock,
+		newLimiter: newLimiter,
+		overrides:  make(map[string]func() Limiter),
+		maxKeys:    maxKeys,
+		idleTTL:    idleTTL,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// SetOverride uses newLimiter instead of the default for the given key.
+// An existing limiter for the key is replaced on next use, and stopped once no caller is using it.
+func (kl *KeyedLimiter) SetOverride(key string, newLimiter func() Limiter) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	kl.overrides[key] = newLimiter
+	kl.remove(key)
+}
+
+// SetClock makes idle eviction use c as its source of time, e.g. a FakeClock in tests.
+// Limiters created for keys get their clock from newLimiter.
+func (kl *KeyedLimiter) SetClock(c Clock) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	kl.clock = c
+}
+
+// Get returns the limiter for key, creating it if needed.
+// Unlike Wait, Allow and Reserve, holding the limiter does not keep it from being evicted and stopped.
+func (kl *KeyedLimiter) Get(key string) Limiter {
+	entry := kl.acquire(key)
+	defer kl.release(entry)
+
+	return entry.limiter
+}
+
+// Wait blocks until the limiter for key permits an action or the context is done.
+func (kl *KeyedLimiter) Wait(ctx context.Context, key string) bool {
+	entry := kl.acquire(key)
+	defer kl.release(entry)
+
+	return entry.limiter.Wait(ctx)
+}
+
+// Allow reports whether the limiter for key permits an action right now.
+func (kl *KeyedLimiter) Allow(key string) bool {
+	entry := kl.acquire(key)
+	defer kl.release(entry)
+
+	return entry.limiter.Allow()
+}
+
+// Reserve claims a token from the limiter for key.
+func (kl *KeyedLimiter) Reserve(key string) *Reservation {
+	entry := kl.acquire(key)
+	defer kl.release(entry)
+
+	return entry.limiter.Reserve()
+}
+
+// Remove forgets the limiter for key, stopping it once no caller is using it.
+func (kl *KeyedLimiter) Remove(key string) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	kl.remove(key)
+}
+
+// Len returns the number of keys currently tracked.
+func (kl *KeyedLimiter) Len() int {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	kl.evictIdle(kl.clock.Now())
+	return kl.lru.Len()
+}
+
+// Stop stops every tracked limiter, including those in use, and forgets all keys.
+func (kl *KeyedLimiter) Stop() {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	for _, el := range kl.entries {
+		el.Value.(*keyedEntry).limiter.Stop()
+	}
+	kl.entries = make(map[string]*list.Element)
+	kl.lru.Init()
+}
+
+// acquire returns the entry for key, creating it if needed, and marks it in use until released.
+func (kl *KeyedLimiter) acquire(key string) *keyedEntry {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	now := kl.clock.Now()
+	kl.evictIdle(now)
+
+	if el, ok := kl.entries[key]; ok {
+		entry := el.Value.(*keyedEntry)
+		entry.lastUsed = now
+		entry.inUse++
+		kl.lru.MoveToFront(el)
+		return entry
+	}
+
+	newLimiter := kl.newLimiter
+	if override, ok := kl.overrides[key]; ok {
+		newLimiter = override
+	}
+
+	entry := &keyedEntry{
+		key:      key,
+		limiter:  newLimiter(),
+		lastUsed: now,
+		inUse:    1,
+	}
+	kl.entries[key] = kl.lru.PushFront(entry)
+	kl.evictOverCap()
+
+	return entry
+}
+
+// release marks entry as no longer used by one caller, stopping its limiter
+// if the key was forgotten in the meantime.
+func (kl *KeyedLimiter) release(entry *keyedEntry) {
+	kl.mu.Lock()
+	defer kl.mu.Unlock()
+
+	entry.inUse--
+	if entry.inUse > 0 {
+		return
+	}
+	if entry.removed {
+		entry.limiter.Stop()
+		return
+	}
+
+	if el, ok := kl.entries[entry.key]; ok && el.Value == entry {
+		entry.lastUsed = kl.clock.Now()
+		kl.lru.MoveToFront(el)
+		kl.evictOverCap()
+	}
+}
+
+// evictIdle removes keys unused for longer than idleTTL. Must be called with mu held.
+func (kl *KeyedLimiter) evictIdle(now time.Time) {
+	if kl.idleTTL <= 0 {
+		return
+	}
+
+	for el := kl.lru.Back(); el != nil; {
+		entry := el.Value.(*keyedEntry)
+		if now.Sub(entry.lastUsed) < kl.idleTTL {
+			return
+		}
+
+		prev := el.Prev()
+		if entry.inUse == 0 {
+			kl.remove(entry.key)
+		}
+		el = prev
+	}
+}
+
+// evictOverCap removes the least recently used keys not in use until at most maxKeys are tracked.
+// Keys in use are kept even if that leaves more than maxKeys. Must be called with mu held.
+func (kl *KeyedLimiter) evictOverCap() {
+	if kl.maxKeys <= 0 {
+		return
+	}
+
+	for el := kl.lru.Back(); el != nil && kl.lru.Len() > kl.maxKeys; {
+		entry := el.Value.(*keyedEntry)
+		prev := el.Prev()
+		if entry.inUse == 0 {
+			kl.remove(entry.key)
+		}
+		el = prev
+	}
+}
+
+// remove forgets the limiter for key, stopping it now if it is not in use
+// and otherwise once its last caller is done. Must be called with mu held.
+func (kl *KeyedLimiter) remove(key string) {
+	el, ok := kl.entries[key]
+	if !ok {
+		return
+	}
+
+	kl.lru.Remove(el)
+	delete(kl.entries, key)
+
+	entry := el.Value.(*keyedEntry)
+	if entry.inUse > 0 {
+		entry.removed = true
+		return
+	}
+	entry.limiter.Stop()
+}
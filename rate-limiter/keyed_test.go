@@ -0,0 +1,228 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestKeyedLimiterPerKey(t *testing.T) {
+	kl := rlm.NewKeyedLimiter(func() rlm.Limiter {
+		return rlm.NewFixedWindow(2, time.Hour)
+	}, 0, 0)
+	defer kl.Stop()
+
+	tests := []struct {
+		name        string
+		key         string
+		attempts    int
+		expectCount int
+	}{
+		{
+			name:        "first host uses its own quota",
+			key:         "a.example.com",
+			attempts:    5,
+			expectCount: 2,
+		},
+		{
+			name:        "second host is not affected by the first",
+			key:         "b.example.com",
+			attempts:    5,
+			expectCount: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count := 0
+			for i := 0; i < tt.attempts; i++ {
+				if kl.Allow(tt.key) {
+					count++
+				}
+			}
+
+			if count != tt.expectCount {
+				t.Errorf("expected %d allowed actions, got %d", tt.expectCount, count)
+			}
+		})
+	}
+}
+
+func TestKeyedLimiterOverride(t *testing.T) {
+	kl := rlm.NewKeyedLimiter(func() rlm.Limiter {
+		return rlm.NewFixedWindow(1, time.Hour)
+	}, 0, 0)
+	defer kl.Stop()
+
+	kl.Allow("api.example.com")
+	kl.SetOverride("api.example.com", func() rlm.Limiter {
+		return rlm.NewFixedWindow(3, time.Hour)
+	})
+
+	count := 0
+	for i := 0; i < 5; i++ {
+		if kl.Allow("api.example.com") {
+			count++
+		}
+	}
+
+	if count != 3 {
+		t.Errorf("expected override to allow 3 actions, got %d", count)
+	}
+}
+
+func TestKeyedLimiterEviction(t *testing.T) {
+	tests := []struct {
+		name      string
+		maxKeys   int
+		idleTTL   time.Duration
+		keys      []string
+		advance   time.Duration
+		expectLen int
+	}{
+		{
+			name:      "lru cap",
+			maxKeys:   2,
+			keys:      []string{"a", "b", "c"},
+			expectLen: 2,
+		},
+		{
+			name:      "idle ttl",
+			idleTTL:   time.Minute,
+			keys:      []string{"a", "b"},
+			advance:   time.Minute,
+			expectLen: 0,
+		},
+		{
+			name:      "no limits",
+			keys:      []string{"a", "b", "c"},
+			expectLen: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stopped []*rlm.FixedWindow
+			kl := rlm.NewKeyedLimiter(func() rlm.Limiter {
+				fw := rlm.NewFixedWindow(1, time.Hour)
+				stopped = append(stopped, fw)
+				return fw
+			}, tt.maxKeys, tt.idleTTL)
+			clock := rlm.NewFakeClock(epoch)
+			kl.SetClock(clock)
+			defer kl.Stop()
+
+			for _, key := range tt.keys {
+				kl.Allow(key)
+			}
+			clock.Advance(tt.advance)
+
+			if got := kl.Len(); got != tt.expectLen {
+				t.Errorf("expected %d tracked keys, got %d", tt.expectLen, got)
+			}
+
+			// evicted limiters are stopped
+			evicted := len(tt.keys) - tt.expectLen
+			for _, fw := range stopped[:evicted] {
+				if fw.Reserve().OK() {
+					t.Error("expected evicted limiter to be stopped")
+				}
+			}
+		})
+	}
+}
+
+// blockingLimiter is a limiter whose Wait blocks until its context is done or it is stopped,
+// reporting when a caller starts waiting.
+type blockingLimiter struct {
+	waiting chan struct{}
+	stopCh  chan struct{}
+}
+
+func newBlockingLimiter() *blockingLimiter {
+	return &blockingLimiter{
+		waiting: make(chan struct{}, 1),
+		stopCh:  make(chan struct{}),
+	}
+}
+
+func (bl *blockingLimiter) Wait(ctx context.Context) bool {
+	bl.waiting <- struct{}{}
+	select {
+	case <-ctx.Done():
+	case <-bl.stopCh:
+	}
+	return false
+}
+
+func (bl *blockingLimiter) Allow() bool               { return true }
+func (bl *blockingLimiter) Reserve() *rlm.Reservation { return &rlm.Reservation{} }
+func (bl *blockingLimiter) Stop()                     { close(bl.stopCh) }
+
+func (bl *blockingLimiter) stopped() bool {
+	select {
+	case <-bl.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestKeyedLimiterKeepsKeysInUse(t *testing.T) {
+	tests := []struct {
+		name    string
+		maxKeys int
+		idleTTL time.Duration
+		evict   func(kl *rlm.KeyedLimiter, clock *rlm.FakeClock)
+		// expectStopped is whether the limiter is stopped once its caller is done
+		expectStopped bool
+	}{
+		{
+			name:    "lru cap",
+			maxKeys: 1,
+			evict:   func(kl *rlm.KeyedLimiter, _ *rlm.FakeClock) { kl.Allow("other") },
+		},
+		{
+			name:    "idle ttl",
+			idleTTL: time.Minute,
+			evict: func(kl *rlm.KeyedLimiter, clock *rlm.FakeClock) {
+				clock.Advance(time.Hour)
+				kl.Allow("other")
+			},
+		},
+		{
+			name:          "remove",
+			evict:         func(kl *rlm.KeyedLimiter, _ *rlm.FakeClock) { kl.Remove("busy") },
+			expectStopped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			busy := newBlockingLimiter()
+			kl := rlm.NewKeyedLimiter(func() rlm.Limiter { return newBlockingLimiter() }, tt.maxKeys, tt.idleTTL)
+			kl.SetOverride("busy", func() rlm.Limiter { return busy })
+			clock := rlm.NewFakeClock(epoch)
+			kl.SetClock(clock)
+			defer kl.Stop()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan bool)
+			go func() { done <- kl.Wait(ctx, "busy") }()
+			<-busy.waiting
+
+			tt.evict(kl, clock)
+			if busy.stopped() {
+				t.Fatal("expected the limiter of a key in use not to be stopped")
+			}
+
+			cancel()
+			<-done
+			if got := busy.stopped(); got != tt.expectStopped {
+				t.Errorf("expected the limiter to be stopped after its caller is done: %v, got %v", tt.expectStopped, got)
+			}
+		})
+	}
+}
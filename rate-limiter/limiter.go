@@ -0,0 +1,127 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// InfDuration is the delay reported by a reservation which can never be acted upon.
+const InfDuration = time.Duration(math.MaxInt64)
+
+// Limiter is implemented by every rate limiting strategy in this package.
+type Limiter interface {
+	// Wait blocks until an action is permitted or the context is done.
+	// Returns true if the action may proceed.
+	Wait(ctx context.Context) bool
+	// Allow reports whether an action may happen right now, consuming a token if so.
+	Allow() bool
+	// Reserve claims a future token and reports how long the caller must wait before using it.
+	Reserve() *Reservation
+	// Stop releases the limiter's resources and any blocked callers.
+	Stop()
+}
+
+var (
+	_ Limiter = RateLimiter{}
+	_ Limiter = (*TokenBucket)(nil)
+	_ Limiter = (*FixedWindow)(nil)
+	_ Limiter = (*SlidingWindowLog)(nil)
+	_ Limiter = (*SlidingWindowCounter)(nil)
+	_ Limiter = (*DistributedLimiter)(nil)
+	_ Limiter = (*AdaptiveLimiter)(nil)
+)
+
+// Reservation is a token claimed from a limiter which may be used once Delay has elapsed.
+type Reservation struct {
+	ok        bool
+	clock     Clock
+	timeToAct time.Time
+	cancel    func()
+	once      sync.Once
+}
+
+// OK reports whether the limiter could grant the reservation at all.
+// A reservation is not OK when the limiter is stopped or can never satisfy it.
+func (r *Reservation) OK() bool {
+	return r.ok
+}
+
+// Delay returns how long the caller must wait before acting on the reservation.
+// Returns InfDuration if the reservation is not OK.
+func (r *Reservation) Delay() time.Duration {
+	if !r.ok {
+		return InfDuration
+	}
+
+	delay := r.timeToAct.Sub(r.now())
+	if delay < 0 {
+		return 0
+	}
+	return delay
+}
+
+// Cancel gives the reserved token back to the limiter.
+// Calling Cancel more than once has no further effect.
+func (r *Reservation) Cancel() {
+	if !r.ok || r.cancel == nil {
+		return
+	}
+	r.once.Do(r.cancel)
+}
+
+func (r *Reservation) now() time.Time {
+	if r.clock == nil {
+		return time.Now()
+	}
+	return r.clock.Now()
+}
+
+func (r *Reservation) newTimer(d time.Duration) Timer {
+	if r.clock == nil {
+		return SystemClock.NewTimer(d)
+	}
+	return r.clock.NewTimer(d)
+}
+
+// waitFor blocks until the reservation may be acted upon.
+// The reservation is given back if the context is done first.
+func waitFor(ctx context.Context, r *Reservation, stopCh <-chan struct{}) error {
+	if !r.OK() {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-stopCh:
+			return ErrLimiterStopped
+		}
+	}
+
+	delay := r.Delay()
+	if delay <= 0 {
+		return nil
+	}
+
+	timer := r.newTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+		return nil
+	case <-ctx.Done():
+		r.Cancel()
+		return ctx.Err()
+	case <-stopCh:
+		return ErrLimiterStopped
+	}
+}
+
+// windowIndex returns the index of the epoch-aligned window containing t.
+func windowIndex(t time.Time, window time.Duration) int64 {
+	return t.UnixNano() / int64(window)
+}
+
+// windowStart returns the start time of the window with the given index.
+func windowStart(idx int64, window time.Duration) time.Time {
+	return time.Unix(0, idx*int64(window))
+}
@@ -0,0 +1,114 @@
+package ratelimiter
+
+import (
+	"context"
+	"time"
+)
+
+// Observer receives events from a limiter, e.g. to export metrics.
+// Implementations must be safe for concurrent use and must not call back into the limiter.
+type Observer interface {
+	// OnAcquire is called when a token is handed out, with how long the caller waited for it.
+	OnAcquire(waited time.Duration)
+	// OnReject is called when an action is refused or a wait gives up without a token,
+	// with how long the caller waited before giving up.
+	OnReject(waited time.Duration)
+	// OnDrop is called when n tokens are discarded at once because the bucket is already full.
+	OnDrop(n int)
+}
+
+// Leveler is implemented by limiters which hold tokens, reporting how full they are.
+type Leveler interface {
+	Level() (tokens float64, capacity int)
+}
+
+var (
+	_ Leveler = RateLimiter{}
+	_ Leveler = (*TokenBucket)(nil)
+	_ Leveler = (*AdaptiveLimiter)(nil)
+)
+
+// Observe wraps l so that every Wait, Allow and Reserve is reported to o.
+// Limiters which discard tokens (RateLimiter, TokenBucket, AdaptiveLimiter) also report
+// drops to o through their SetObserver method.
+func Observe(l Limiter, o Observer) Limiter {
+	if s, ok := l.(interface{ SetObserver(Observer) }); ok {
+		s.SetObserver(o)
+	}
+	return &observedLimiter{
+		Limiter:  l,
+		observer: o,
+	}
+}
+
+type observedLimiter struct {
+	Limiter
+	observer Observer
+}
+
+// clocked is implemented by the limiters of this package, reporting the clock they currently use.
+type clocked interface {
+	currentClock() Clock
+}
+
+// clockOf returns the clock l uses, or SystemClock if it does not say.
+func clockOf(l Limiter) Clock {
+	if c, ok := l.(clocked); ok {
+		return c.currentClock()
+	}
+	return SystemClock
+}
+
+func (ol *observedLimiter) Wait(ctx context.Context) bool {
+	clock := clockOf(ol.Limiter)
+	start := clock.Now()
+	acquired := ol.Limiter.Wait(ctx)
+
+	if acquired {
+		ol.observer.OnAcquire(clock.Now().Sub(start))
+	} else {
+		ol.observer.OnReject(clock.Now().Sub(start))
+	}
+	return acquired
+}
+
+func (ol *observedLimiter) Allow() bool {
+	allowed := ol.Limiter.Allow()
+
+	if allowed {
+		ol.observer.OnAcquire(0)
+	} else {
+		ol.observer.OnReject(0)
+	}
+	return allowed
+}
+
+func (ol *observedLimiter) Reserve() *Reservation {
+	r := ol.Limiter.Reserve()
+
+	if r.OK() {
+		ol.observer.OnAcquire(r.Delay())
+	} else {
+		ol.observer.OnReject(0)
+	}
+	return r
+}
+
+// SetClock makes the wrapped limiter, and so the waits reported, use c as the source of time.
+func (ol *observedLimiter) SetClock(c Clock) {
+	if s, ok := ol.Limiter.(interface{ SetClock(Clock) }); ok {
+		s.SetClock(c)
+	}
+}
+
+func (ol *observedLimiter) currentClock() Clock {
+	return clockOf(ol.Limiter)
+}
+
+// Level reports how full the wrapped limiter is, if it holds tokens.
+func (ol *observedLimiter) Level() (float64, int) {
+	if l, ok := ol.Limiter.(Leveler); ok {
+		return l.Level()
+	}
+	return 0, 0
+}
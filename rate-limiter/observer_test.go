@@ -0,0 +1,109 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+// recordingObserver counts the events it receives.
+type recordingObserver struct {
+	mu       sync.Mutex
+	acquired int
+	rejected int
+	dropped  int
+	drops    int // calls to OnDrop
+	waited   time.Duration
+}
+
+func (o *recordingObserver) OnAcquire(waited time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.acquired++
+	o.waited += waited
+}
+
+func (o *recordingObserver) OnReject(time.Duration) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.rejected++
+}
+
+func (o *recordingObserver) OnDrop(n int) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.dropped += n
+	o.drops++
+}
+
+func TestObserveReportsOutcomes(t *testing.T) {
+	tb, _ := newFakeTokenBucket(1, time.Hour, 2)
+	o := &recordingObserver{}
+	l := rlm.Observe(tb, o)
+	defer l.Stop()
+
+	if !l.Wait(context.Background()) {
+		t.Fatal("expected the first token to be immediate")
+	}
+	if !l.Allow() {
+		t.Fatal("expected the second token to be immediate")
+	}
+	if l.Allow() {
+		t.Fatal("expected the bucket to be empty")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if l.Wait(ctx) {
+		t.Fatal("expected Wait to fail with a canceled context")
+	}
+
+	if o.acquired != 2 || o.rejected != 2 {
+		t.Errorf("expected 2 acquired and 2 rejected, got %d and %d", o.acquired, o.rejected)
+	}
+}
+
+func TestObserveUsesLimiterClock(t *testing.T) {
+	tb, clock := newFakeTokenBucket(1, time.Second, 1)
+	o := &recordingObserver{}
+	l := rlm.Observe(tb, o)
+	defer l.Stop()
+
+	l.Allow()
+	done := make(chan bool)
+	go func() { done <- l.Wait(waitCtx(t)) }()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	if !<-done {
+		t.Fatal("expected a token after the refill")
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.waited != time.Second {
+		t.Errorf("expected the wait to be measured on the fake clock as %s, got %s", time.Second, o.waited)
+	}
+}
+
+func TestTokenBucketReportsDrops(t *testing.T) {
+	tb, clock := newFakeTokenBucket(10, time.Second, 2)
+	defer tb.Stop()
+
+	o := &recordingObserver{}
+	tb.SetObserver(o)
+
+	tb.Allow()
+	clock.Advance(time.Second)
+
+	tokens, capacity := tb.Level()
+	if tokens != 2 || capacity != 2 {
+		t.Errorf("expected a full bucket of 2, got %v of %d", tokens, capacity)
+	}
+	if o.dropped != 9 || o.drops != 1 {
+		t.Errorf("expected 9 dropped tokens in one report, got %d in %d", o.dropped, o.drops)
+	}
+}
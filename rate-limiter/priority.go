@@ -0,0 +1,236 @@
+package ratelimiter
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// Priority orders callers waiting on a PriorityLimiter. Higher priorities are served first.
+type Priority int
+
+const (
+	// PriorityBackground is for bulk work which may wait behind everything else.
+	PriorityBackground Priority = iota
+	// PriorityNormal is the priority of callers which do not set one.
+	PriorityNormal
+	// PriorityInteractive is for work a user is waiting on.
+	PriorityInteractive
+
+	numPriorities
+)
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of ctx carrying p, which PriorityLimiter uses to order waiters.
+func WithPriority(ctx context.Context, p Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, p)
+}
+
+// PriorityFrom returns the priority carried by ctx, or PriorityNormal if it has none.
+func PriorityFrom(ctx context.Context) Priority {
+	if p, ok := ctx.Value(priorityKey{}).(Priority); ok && p >= 0 && p < numPriorities {
+		return p
+	}
+	return PriorityNormal
+}
+
+// PriorityLimiter queues callers of a limiter and hands out tokens by priority,
+// taken from the context with PriorityFrom, then in arrival order within a priority.
+// Only the caller at the head of the queue waits on the wrapped limiter, so a
+// weighted WaitN is not starved by a stream of single-token waits either.
+type PriorityLimiter struct {
+	Limiter
+
+	mu      sync.Mutex
+	queues  [numPriorities]*list.List
+	serving bool // a waiter is acquiring tokens from the wrapped limiter
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+type priorityWaiter struct {
+	turn chan struct{} // closed when the waiter reaches the head of the queue
+}
+
+var _ Limiter = (*PriorityLimiter)(nil)
+
+// NewPriorityLimiter wraps l so that waiters are served by priority.
+func NewPriorityLimiter(l Limiter) *PriorityLimiter {
+	pl := &PriorityLimiter{
+		Limiter: l,
+		stopCh:  make(chan struct{}),
+	}
+	for i := range pl.queues {
+		pl.queues[i] = list.New()
+	}
+	return pl
+}
+
+// Wait blocks until every caller of higher priority, and every earlier caller of the same
+// priority, has been served and a token is available, or the context is done.
+// Returns true if a token was acquired.
+func (pl *PriorityLimiter) Wait(ctx context.Context) bool {
+	return pl.WaitN(ctx, 1) == nil
+}
+
+// WaitN is like Wait but acquires n tokens at once, e.g. for an expensive operation.
+// Returns ErrExceedsBurst if the wrapped limiter can never hold n tokens, ErrInvalidTokens
+// if n is not positive, ErrLimiterStopped if the limiter was stopped, or the context error.
+func (pl *PriorityLimiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return ErrInvalidTokens
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-pl.stopCh:
+		return ErrLimiterStopped
+	default:
+	}
+
+	p := PriorityFrom(ctx)
+	w := &priorityWaiter{turn: make(chan struct{})}
+	pl.mu.Lock()
+	el := pl.queues[p].PushBack(w)
+	pl.dispatch()
+	pl.mu.Unlock()
+
+	select {
+	case <-w.turn:
+	case <-ctx.Done():
+		if pl.leave(p, el) {
+			return ctx.Err()
+		}
+	case <-pl.stopCh:
+		if pl.leave(p, el) {
+			return ErrLimiterStopped
+		}
+	}
+	defer pl.done()
+
+	return waitN(ctx, pl.Limiter, n)
+}
+
+// Allow reports whether an action may happen right now, consuming a token if so.
+// Always false while callers are queued, so it cannot jump ahead of them.
+func (pl *PriorityLimiter) Allow() bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	if pl.serving {
+		return false
+	}
+	return pl.Limiter.Allow()
+}
+
+// Len returns the number of callers waiting with priority p, excluding the one being served.
+func (pl *PriorityLimiter) Len(p Priority) int {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	return pl.queues[p].Len()
+}
+
+func (pl *PriorityLimiter) currentClock() Clock {
+	return clockOf(pl.Limiter)
+}
+
+// Stop stops the wrapped limiter and releases every queued caller.
+func (pl *PriorityLimiter) Stop() {
+	pl.stopOnce.Do(func() {
+		close(pl.stopCh)
+	})
+	pl.Limiter.Stop()
+}
+
+// dispatch gives the turn to the first waiter of the highest priority if nobody is being served.
+// Must be called with mu held.
+func (pl *PriorityLimiter) dispatch() {
+	if pl.serving {
+		return
+	}
+
+	for p := numPriorities - 1; p >= 0; p-- {
+		if el := pl.queues[p].Front(); el != nil {
+			pl.queues[p].Remove(el)
+			pl.serving = true
+			close(el.Value.(*priorityWaiter).turn)
+			return
+		}
+	}
+}
+
+// leave removes a waiter which gave up from its queue. Reports false if the waiter
+// was given the turn meanwhile, in which case it must still call done.
+func (pl *PriorityLimiter) leave(p Priority, el *list.Element) bool {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	select {
+	case <-el.Value.(*priorityWaiter).turn:
+		return false
+	default:
+	}
+	pl.queues[p].Remove(el)
+	return true
+}
+
+// done ends the current turn and passes it on.
+func (pl *PriorityLimiter) done() {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+
+	pl.serving = false
+	pl.dispatch()
+}
+
+// waitN acquires n tokens from l, using its WaitN if it has one. Otherwise n tokens
+// are reserved together and given back if the context is done before they are ready.
+func waitN(ctx context.Context, l Limiter, n int) error {
+	if wl, ok := l.(interface {
+		WaitN(ctx context.Context, n int) error
+	}); ok {
+		return wl.WaitN(ctx, n)
+	}
+
+	if n == 1 {
+		if !l.Wait(ctx) {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			return ErrLimiterStopped
+		}
+		return nil
+	}
+
+	reservations := make([]*Reservation, 0, n)
+	cancelAll := func() {
+		for _, r := range reservations {
+			r.Cancel()
+		}
+	}
+
+	for range n {
+		r := l.Reserve()
+		if !r.OK() {
+			cancelAll()
+			return ErrLimiterStopped
+		}
+		reservations = append(reservations, r)
+	}
+
+	last := reservations[0]
+	for _, r := range reservations[1:] {
+		if r.Delay() > last.Delay() {
+			last = r
+		}
+	}
+	if err := waitFor(ctx, last, nil); err != nil {
+		cancelAll()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+// waitQueued polls until n callers with priority p are queued on pl.
+func waitQueued(t *testing.T, pl *rlm.PriorityLimiter, p rlm.Priority, n int) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for pl.Len(p) < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d callers queued with priority %d, got %d", n, p, pl.Len(p))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPriorityLimiterServesInteractiveFirst(t *testing.T) {
+	tb, clock := newFakeTokenBucket(1, time.Second, 1)
+	pl := rlm.NewPriorityLimiter(tb)
+	defer pl.Stop()
+
+	tb.Allow()
+
+	order := make(chan string, 3)
+	wait := func(name string, p rlm.Priority) {
+		if pl.Wait(rlm.WithPriority(context.Background(), p)) {
+			order <- name
+		}
+	}
+
+	go wait("first", rlm.PriorityBackground)
+	clock.BlockUntil(1)
+	go wait("batch", rlm.PriorityBackground)
+	waitQueued(t, pl, rlm.PriorityBackground, 1)
+	go wait("interactive", rlm.PriorityInteractive)
+	waitQueued(t, pl, rlm.PriorityInteractive, 1)
+
+	for _, expected := range []string{"first", "interactive", "batch"} {
+		clock.BlockUntil(1)
+		clock.Advance(time.Second)
+		if got := <-order; got != expected {
+			t.Fatalf("expected %s to be served next, got %s", expected, got)
+		}
+	}
+}
+
+func TestPriorityLimiterWaitN(t *testing.T) {
+	fw := rlm.NewFixedWindow(3, time.Hour)
+	fw.SetClock(rlm.NewFakeClock(epoch))
+	pl := rlm.NewPriorityLimiter(fw)
+	defer pl.Stop()
+
+	if err := pl.WaitN(waitCtx(t), 0); !errors.Is(err, rlm.ErrInvalidTokens) {
+		t.Errorf("expected ErrInvalidTokens, got %v", err)
+	}
+	if err := pl.WaitN(waitCtx(t), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !pl.Allow() {
+		t.Fatal("expected one token to be left")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := pl.WaitN(ctx, 2); err == nil {
+		t.Fatal("expected WaitN to time out in a full window")
+	}
+}
+
+func TestPriorityFromDefault(t *testing.T) {
+	if p := rlm.PriorityFrom(context.Background()); p != rlm.PriorityNormal {
+		t.Errorf("expected PriorityNormal, got %d", p)
+	}
+}
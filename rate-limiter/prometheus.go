@@ -0,0 +1,235 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWaitBuckets are the upper bounds, in seconds, of the wait time histogram buckets.
+var DefaultWaitBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5, 10, 30, 60}
+
+// PrometheusMetrics collects events from any number of limiters and serves them
+// in the Prometheus text exposition format, e.g. mounted at /metrics.
+type PrometheusMetrics struct {
+	mu       sync.Mutex
+	buckets  []float64
+	limiters map[string]*limiterMetrics
+}
+
+// limiterMetrics holds the metrics of one limiter. It implements Observer.
+type limiterMetrics struct {
+	mu       sync.Mutex
+	buckets  []float64
+	leveler  Leveler
+	acquired uint64
+	rejected uint64
+	dropped  uint64
+	waits    map[string]*histogram // by outcome
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative; the last entry counts values above every bound
+	sum    float64
+	count  uint64
+}
+
+var _ http.Handler = (*PrometheusMetrics)(nil)
+
+// NewPrometheusMetrics creates an empty collector using the given wait time buckets in seconds.
+// A nil buckets slice uses DefaultWaitBuckets.
+func NewPrometheusMetrics(buckets []float64) *PrometheusMetrics {
+	if buckets == nil {
+		buckets = DefaultWaitBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusMetrics{
+		buckets:  buckets,
+		limiters: make(map[string]*limiterMetrics),
+	}
+}
+
+// Observer returns the Observer recording events under the label limiter=name,
+// to be passed to Observe. If l is not nil its level is exported as a gauge.
+// Calling Observer again with the same name returns the same Observer.
+func (pm *PrometheusMetrics) Observer(name string, l Leveler) Observer {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if lm, ok := pm.limiters[name]; ok {
+		if l != nil {
+			lm.mu.Lock()
+			lm.leveler = l
+			lm.mu.Unlock()
+		}
+		return lm
+	}
+
+	lm := &limiterMetrics{
+		buckets: pm.buckets,
+		leveler: l,
+		waits:   make(map[string]*histogram),
+	}
+	pm.limiters[name] = lm
+	return lm
+}
+
+// ServeHTTP writes the current metrics of every limiter.
+func (pm *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	pm.WriteTo(w)
+}
+
+// WriteTo writes the current metrics of every limiter to w in the Prometheus text format.
+func (pm *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	pm.mu.Lock()
+	names := make([]string, 0, len(pm.limiters))
+	for name := range pm.limiters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	limiters := make([]*limiterMetrics, len(names))
+	for i, name := range names {
+		limiters[i] = pm.limiters[name]
+	}
+	pm.mu.Unlock()
+
+	snapshots := make([]*limiterMetrics, len(limiters))
+	for i, lm := range limiters {
+		snapshots[i] = lm.snapshot()
+	}
+
+	var b strings.Builder
+
+	writeCounter := func(metric, help string, value func(*limiterMetrics) uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", metric, help, metric)
+		for i, name := range names {
+			fmt.Fprintf(&b, "%s{limiter=%s} %d\n", metric, quote(name), value(snapshots[i]))
+		}
+	}
+	writeCounter("ratelimiter_acquired_total", "Tokens handed out.",
+		func(lm *limiterMetrics) uint64 { return lm.acquired })
+	writeCounter("ratelimiter_rejected_total", "Actions refused or waits given up without a token.",
+		func(lm *limiterMetrics) uint64 { return lm.rejected })
+	writeCounter("ratelimiter_dropped_total", "Tokens discarded because the bucket was full.",
+		func(lm *limiterMetrics) uint64 { return lm.dropped })
+
+	b.WriteString("# HELP ratelimiter_wait_seconds Time callers waited for a token, by outcome.\n")
+	b.WriteString("# TYPE ratelimiter_wait_seconds histogram\n")
+	for i, name := range names {
+		outcomes := make([]string, 0, len(snapshots[i].waits))
+		for outcome := range snapshots[i].waits {
+			outcomes = append(outcomes, outcome)
+		}
+		sort.Strings(outcomes)
+
+		for _, outcome := range outcomes {
+			h := snapshots[i].waits[outcome]
+			labels := fmt.Sprintf("limiter=%s,outcome=%s", quote(name), quote(outcome))
+
+			var cumulative uint64
+			for j, bound := range pm.buckets {
+				cumulative += h.counts[j]
+				fmt.Fprintf(&b, "ratelimiter_wait_seconds_bucket{%s,le=%s} %d\n",
+					labels, quote(formatFloat(bound)), cumulative)
+			}
+			fmt.Fprintf(&b, "ratelimiter_wait_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, h.count)
+			fmt.Fprintf(&b, "ratelimiter_wait_seconds_sum{%s} %s\n", labels, formatFloat(h.sum))
+			fmt.Fprintf(&b, "ratelimiter_wait_seconds_count{%s} %d\n", labels, h.count)
+		}
+	}
+
+	b.WriteString("# HELP ratelimiter_tokens Tokens currently held for later use.\n")
+	b.WriteString("# TYPE ratelimiter_tokens gauge\n")
+	var capacities strings.Builder
+	for i, name := range names {
+		if snapshots[i].leveler == nil {
+			continue
+		}
+		tokens, capacity := snapshots[i].leveler.Level()
+		fmt.Fprintf(&b, "ratelimiter_tokens{limiter=%s} %s\n", quote(name), formatFloat(tokens))
+		fmt.Fprintf(&capacities, "ratelimiter_capacity{limiter=%s} %d\n", quote(name), capacity)
+	}
+	b.WriteString("# HELP ratelimiter_capacity Maximum number of tokens held for later use.\n")
+	b.WriteString("# TYPE ratelimiter_capacity gauge\n")
+	b.WriteString(capacities.String())
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func (lm *limiterMetrics) OnAcquire(waited time.Duration) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.acquired++
+	lm.observeWait("acquired", waited)
+}
+
+func (lm *limiterMetrics) OnReject(waited time.Duration) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.rejected++
+	lm.observeWait("rejected", waited)
+}
+
+func (lm *limiterMetrics) OnDrop(n int) {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	lm.dropped += uint64(n)
+}
+
+// observeWait adds a wait to the histogram for outcome. Must be called with mu held.
+func (lm *limiterMetrics) observeWait(outcome string, waited time.Duration) {
+	h, ok := lm.waits[outcome]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(lm.buckets)+1)}
+		lm.waits[outcome] = h
+	}
+
+	secs := waited.Seconds()
+	h.counts[sort.SearchFloat64s(lm.buckets, secs)]++
+	h.sum += secs
+	h.count++
+}
+
+// snapshot copies the counters and histograms so they can be written without holding mu.
+func (lm *limiterMetrics) snapshot() *limiterMetrics {
+	lm.mu.Lock()
+	defer lm.mu.Unlock()
+
+	s := &limiterMetrics{
+		leveler:  lm.leveler,
+		acquired: lm.acquired,
+		rejected: lm.rejected,
+		dropped:  lm.dropped,
+		waits:    make(map[string]*histogram, len(lm.waits)),
+	}
+	for outcome, h := range lm.waits {
+		s.waits[outcome] = &histogram{
+			counts: append([]uint64(nil), h.counts...),
+			sum:    h.sum,
+			count:  h.count,
+		}
+	}
+	return s
+}
+
+// quote formats a label value, escaping backslashes, quotes and newlines.
+func quote(s string) string {
+	s = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+	return `"` + s + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,46 @@
+package ratelimiter_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestPrometheusMetrics(t *testing.T) {
+	pm := rlm.NewPrometheusMetrics([]float64{0.1, 1})
+
+	tb, _ := newFakeTokenBucket(1, time.Hour, 3)
+	defer tb.Stop()
+	l := rlm.Observe(tb, pm.Observer("scraper", tb))
+
+	l.Allow()
+	l.Allow()
+	o := pm.Observer("scraper", nil)
+	o.OnAcquire(500 * time.Millisecond)
+	o.OnReject(2 * time.Second)
+
+	rec := httptest.NewRecorder()
+	pm.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := rec.Body.String()
+
+	for _, line := range []string{
+		`ratelimiter_acquired_total{limiter="scraper"} 3`,
+		`ratelimiter_rejected_total{limiter="scraper"} 1`,
+		`ratelimiter_dropped_total{limiter="scraper"} 0`,
+		`ratelimiter_wait_seconds_bucket{limiter="scraper",outcome="acquired",le="0.1"} 2`,
+		`ratelimiter_wait_seconds_bucket{limiter="scraper",outcome="acquired",le="1"} 3`,
+		`ratelimiter_wait_seconds_bucket{limiter="scraper",outcome="rejected",le="1"} 0`,
+		`ratelimiter_wait_seconds_bucket{limiter="scraper",outcome="rejected",le="+Inf"} 1`,
+		`ratelimiter_wait_seconds_sum{limiter="scraper",outcome="rejected"} 2`,
+		`ratelimiter_tokens{limiter="scraper"} 1`,
+		`ratelimiter_capacity{limiter="scraper"} 3`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected metrics to contain %q, got:\n%s", line, body)
+		}
+	}
+}
@@ -2,12 +2,45 @@ package ratelimiter
 
 import (
 	"context"
+	"errors"
+	"sync"
 	"time"
 )
 
+// ErrInvalidLimit is returned when a limiter is reconfigured with a non-positive rate or burst.
+var ErrInvalidLimit = errors.New("rate and burst must be positive")
+
+// MaxBurst is the largest burst size a RateLimiter can be reconfigured to with SetBurst.
+// Tokens carry no data, so the token channel is sized to it without costing memory.
+const MaxBurst = 1 << 20
+
 type RateLimiter struct {
 	limitCh chan struct{}
 	stopCh  chan struct{}
+	state   *tickerState
+}
+
+// RateLimiterConfig describes the current settings of a RateLimiter.
+type RateLimiterConfig struct {
+	// Interval is the time between two tokens.
+	Interval time.Duration
+	// Burst is the maximum number of tokens held for later use.
+	Burst int
+}
+
+// tickerState is shared by all copies of a RateLimiter and its refill goroutine.
+type tickerState struct {
+	mu       sync.Mutex
+	clock    Clock
+	interval time.Duration
+	burst    int
+	nextTick time.Time
+	pending  int // upcoming ticks promised to outstanding reservations
+	observer Observer
+	stopOnce sync.Once
+
+	// changed wakes the refill goroutine after its settings change.
+	changed chan struct{}
 }
 
 // NewRateLimiter creates a rate limiter allowing `rpi` actions per `interval`.
@@ -18,39 +51,226 @@ func NewRateLimiter(
 	ctx context.Context,
 	timeSpan time.Duration,
 	intervals int) RateLimiter {
-	limitCh := make(chan struct{}, intervals)
-	stopCh := make(chan struct{})
+	interval := timeSpan / time.Duration(intervals)
+	rl := RateLimiter{
+		limitCh: make(chan struct{}, max(intervals, MaxBurst)),
+		stopCh:  make(chan struct{}),
+		state: &tickerState{
+			clock:    SystemClock,
+			interval: interval,
+			burst:    intervals,
+			nextTick: SystemClock.Now().Add(interval),
+			changed:  make(chan struct{}, 1),
+		},
+	}
 
 	go func() {
-		ticker := time.NewTicker(timeSpan / time.Duration(intervals))
-		defer ticker.Stop()
+		clock, current := SystemClock, interval
+		ticker := clock.NewTicker(current)
+		defer func() { ticker.Stop() }()
 		for {
 			select {
-			case <-ticker.C:
-				limitCh <- struct{}{}
+			case <-ticker.C():
+				if rl.state.claimTick() {
+					continue
+				}
+				rl.addToken()
+			case <-rl.state.changed:
+				newClock, interval := rl.state.settings()
+				if newClock != clock {
+					ticker.Stop()
+					clock, current = newClock, interval
+					ticker = clock.NewTicker(current)
+				} else if interval != current {
+					current = interval
+					ticker.Reset(current)
+				}
 			case <-ctx.Done():
+				rl.Stop()
 				return
-			case <-stopCh:
+			case <-rl.stopCh:
 				return
 			}
 		}
 	}()
 
-	return RateLimiter{
-		limitCh: limitCh,
-		stopCh:  stopCh,
-	}
+	return rl
 }
 
-// Wait blocks until a token is available or the context is done.
-// Returns true if a token was acquired, false if context was canceled.
+// Wait blocks until a token is available, the context is done or the limiter is stopped.
+// Returns true if a token was acquired, false if context was canceled or the limiter was stopped.
 func (rl RateLimiter) Wait(ctx context.Context) bool {
+	if rl.stopped() {
+		return false
+	}
+
 	select {
 	case <-rl.limitCh:
 		return true
 	case <-ctx.Done():
 		return false
+	case <-rl.stopCh:
+		return false
+	}
+}
+
+// WaitN blocks until n tokens are available, the context is done or the limiter is stopped.
+// Returns ErrExceedsBurst if n is larger than the burst size, ErrInvalidTokens if n is not positive,
+// ErrLimiterStopped if the limiter was stopped, or the context error. Tokens taken by a canceled
+// wait are given back.
+func (rl RateLimiter) WaitN(ctx context.Context, n int) error {
+	if n <= 0 {
+		return ErrInvalidTokens
+	}
+	if n > rl.Config().Burst {
+		return ErrExceedsBurst
+	}
+	if rl.stopped() {
+		return ErrLimiterStopped
+	}
+
+	for taken := 0; taken < n; taken++ {
+		select {
+		case <-rl.limitCh:
+		case <-ctx.Done():
+			rl.giveBack(taken)
+			return ctx.Err()
+		case <-rl.stopCh:
+			rl.giveBack(taken)
+			return ErrLimiterStopped
+		}
+	}
+	return nil
+}
+
+// Allow reports whether a token is available right now, consuming it if so.
+// It never blocks, which suits callers that would rather drop work than queue it.
+func (rl RateLimiter) Allow() bool {
+	select {
+	case <-rl.limitCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// Reserve claims a token and reports how long until it may be used.
+// If no token is available, one of the upcoming ticks is set aside for the reservation.
+// Canceling the reservation gives the token back.
+func (rl RateLimiter) Reserve() *Reservation {
+	if rl.stopped() {
+		return &Reservation{}
+	}
+
+	select {
+	case <-rl.limitCh:
+		return &Reservation{
+			ok:        true,
+			clock:     rl.state.currentClock(),
+			timeToAct: rl.state.currentClock().Now(),
+			cancel:    rl.addToken,
+		}
+	default:
+	}
+
+	s := rl.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending++
+	timeToAct := s.nextTick.Add(time.Duration(s.pending-1) * s.interval)
+
+	return &Reservation{
+		ok:        true,
+		clock:     s.clock,
+		timeToAct: timeToAct,
+		cancel:    func() { rl.cancelPending(timeToAct) },
+	}
+}
+
+// SetRate changes the limiter to allow `intervals` actions per `timeSpan`, like NewRateLimiter.
+// The change applies from the next tick, including for goroutines already blocked in Wait.
+// The burst size is left unchanged; use SetBurst to change it.
+func (rl RateLimiter) SetRate(timeSpan time.Duration, intervals int) error {
+	if timeSpan <= 0 || intervals <= 0 || timeSpan/time.Duration(intervals) <= 0 {
+		return ErrInvalidLimit
+	}
+
+	s := rl.state
+	s.mu.Lock()
+	s.interval = timeSpan / time.Duration(intervals)
+	s.nextTick = s.clock.Now().Add(s.interval)
+	s.mu.Unlock()
+
+	s.notify()
+	return nil
+}
+
+// SetClock makes the limiter use c as its source of time, e.g. a FakeClock in tests.
+// The refill goroutine switches to a ticker from c, starting a fresh interval.
+func (rl RateLimiter) SetClock(c Clock) {
+	s := rl.state
+	s.mu.Lock()
+	s.clock = c
+	s.nextTick = c.Now().Add(s.interval)
+	s.mu.Unlock()
+
+	s.notify()
+}
+
+// SetBurst changes the maximum number of tokens held for later use, up to MaxBurst.
+// Tokens held beyond the new burst size are dropped.
+func (rl RateLimiter) SetBurst(burst int) error {
+	if burst <= 0 || burst > cap(rl.limitCh) {
+		return ErrInvalidLimit
 	}
+
+	s := rl.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.burst = burst
+	dropped := 0
+	for len(rl.limitCh) > burst {
+		select {
+		case <-rl.limitCh:
+			dropped++
+		default:
+		}
+	}
+	s.drop(dropped)
+	return nil
+}
+
+// Config returns the limiter's current settings.
+func (rl RateLimiter) Config() RateLimiterConfig {
+	s := rl.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return RateLimiterConfig{
+		Interval: s.interval,
+		Burst:    s.burst,
+	}
+}
+
+// Level returns the number of tokens held for later use and the burst size.
+func (rl RateLimiter) Level() (float64, int) {
+	s := rl.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return float64(len(rl.limitCh)), s.burst
+}
+
+// SetObserver makes the limiter report tokens dropped because the bucket is full to o.
+// Use Observe to also report waits, acquisitions and rejections.
+func (rl RateLimiter) SetObserver(o Observer) {
+	s := rl.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.observer = o
 }
 
 // LimitCh returns the internal token channel used for rate limiting.
@@ -62,5 +282,97 @@ func (rl RateLimiter) LimitCh(ctx context.Context) <-chan struct{} {
 
 // Stops the ratelimiter
 func (rl RateLimiter) Stop() {
-	rl.stopCh <- struct{}{}
+	rl.state.stopOnce.Do(func() {
+		close(rl.stopCh)
+	})
+}
+
+func (rl RateLimiter) currentClock() Clock {
+	return rl.state.currentClock()
+}
+
+// stopped reports whether Stop was called or the context of the limiter is done.
+func (rl RateLimiter) stopped() bool {
+	select {
+	case <-rl.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// giveBack returns tokens taken by a wait which gave up.
+func (rl RateLimiter) giveBack(n int) {
+	for ; n > 0; n-- {
+		rl.addToken()
+	}
+}
+
+// cancelPending releases a reservation made for the tick expected at timeToAct.
+func (rl RateLimiter) cancelPending(timeToAct time.Time) {
+	s := rl.state
+	s.mu.Lock()
+	if s.clock.Now().Before(timeToAct) && s.pending > 0 {
+		s.pending--
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	// the tick was already claimed for this reservation
+	rl.addToken()
+}
+
+// addToken adds a token to the bucket unless it is already full.
+func (rl RateLimiter) addToken() {
+	s := rl.state
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(rl.limitCh) < s.burst {
+		rl.limitCh <- struct{}{}
+		return
+	}
+	s.drop(1)
+}
+
+// claimTick records a tick and reports whether it was set aside for a reservation.
+func (s *tickerState) claimTick() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.nextTick = s.clock.Now().Add(s.interval)
+	if s.pending > 0 {
+		s.pending--
+		return true
+	}
+	return false
+}
+
+// drop reports n discarded tokens to the observer. Must be called with mu held.
+func (s *tickerState) drop(n int) {
+	if s.observer != nil && n > 0 {
+		s.observer.OnDrop(n)
+	}
+}
+
+func (s *tickerState) settings() (Clock, time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.clock, s.interval
+}
+
+func (s *tickerState) currentClock() Clock {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.clock
+}
+
+func (s *tickerState) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
 }
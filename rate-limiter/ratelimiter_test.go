@@ -2,12 +2,58 @@ package ratelimiter_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 	"time"
 
 	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
 )
 
+// epoch is the start time of fake clocks, aligned to window boundaries.
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// newFakeRateLimiter creates a RateLimiter whose refill goroutine runs on a fake clock.
+func newFakeRateLimiter(
+	ctx context.Context,
+	timeSpan time.Duration,
+	intervals int) (rlm.RateLimiter, *rlm.FakeClock) {
+	clock := rlm.NewFakeClock(epoch)
+	rl := rlm.NewRateLimiter(ctx, timeSpan, intervals)
+	rl.SetClock(clock)
+	clock.BlockUntil(1)
+	return rl, clock
+}
+
+// tick advances clock by d and polls until the refill goroutine of rl has handled the tick, by
+// adding a token or by dropping it to o, so that the next advance is not merged with this one.
+func tick(t *testing.T, rl rlm.RateLimiter, clock *rlm.FakeClock, d time.Duration, o *recordingObserver) {
+	t.Helper()
+
+	handled := func() int {
+		tokens, _ := rl.Level()
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		return int(tokens) + o.dropped
+	}
+
+	before := handled()
+	clock.Advance(d)
+	deadline := time.Now().Add(time.Second)
+	for handled() == before {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the tick to reach the refill goroutine")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// waitCtx bounds waits which are expected to succeed, so a broken limiter fails the test instead of hanging it.
+func waitCtx(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	t.Cleanup(cancel)
+	return ctx
+}
+
 func TestRateLimiterBasic(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -37,21 +83,25 @@ func TestRateLimiterBasic(t *testing.T) {
 			ctx, cancel := context.WithCancel(context.Background())
 			defer cancel()
 
-			rl := rlm.NewRateLimiter(ctx, tt.timeSpan, tt.intervals)
+			rl, clock := newFakeRateLimiter(ctx, tt.timeSpan, tt.intervals)
 			defer rl.Stop()
 
+			interval := tt.timeSpan / time.Duration(tt.intervals)
 			count := 0
-			timeout := time.After(tt.waitTime + 100*time.Millisecond)
-		loop:
-			for {
+			for elapsed := time.Duration(0); elapsed < tt.waitTime; elapsed += interval {
+				clock.Advance(interval)
 				select {
 				case <-rl.LimitCh(ctx):
 					count++
-				case <-timeout:
-					break loop
+				case <-waitCtx(t).Done():
+					t.Fatalf("timed out waiting for token %d", count+1)
 				}
 			}
 
+			if rl.Allow() {
+				t.Error("expected every token to be taken")
+			}
+
 			if count != tt.expectCount {
 				t.Errorf("expected %d actions, got %d", tt.expectCount, count)
 			}
@@ -74,3 +124,219 @@ func TestRateLimiterStops(t *testing.T) {
 	}
 	cancel()
 }
+
+func TestRateLimiterWaitReturnsOnStop(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rl, _ := newFakeRateLimiter(ctx, time.Second, 2)
+
+	waitDone := make(chan bool)
+	waitNDone := make(chan error)
+	go func() { waitDone <- rl.Wait(waitCtx(t)) }()
+	go func() { waitNDone <- rl.WaitN(waitCtx(t), 2) }()
+
+	rl.Stop()
+	if <-waitDone {
+		t.Error("expected Wait to give up when the limiter is stopped")
+	}
+	if err := <-waitNDone; !errors.Is(err, rlm.ErrLimiterStopped) {
+		t.Errorf("expected ErrLimiterStopped, got %v", err)
+	}
+
+	// callers arriving after Stop do not block either
+	if rl.Wait(ctx) {
+		t.Error("expected Wait to fail after Stop")
+	}
+	if err := rl.WaitN(ctx, 1); !errors.Is(err, rlm.ErrLimiterStopped) {
+		t.Errorf("expected ErrLimiterStopped after Stop, got %v", err)
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rl, clock := newFakeRateLimiter(ctx, time.Second, 2)
+	defer rl.Stop()
+
+	if rl.Allow() {
+		t.Error("expected no token before the first tick")
+	}
+
+	clock.Advance(500 * time.Millisecond)
+	if !rl.Wait(waitCtx(t)) {
+		t.Fatal("expected a token after the first tick")
+	}
+	if rl.Allow() {
+		t.Error("expected the token to be consumed")
+	}
+}
+
+func TestRateLimiterReserve(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rl, clock := newFakeRateLimiter(ctx, time.Second, 10)
+	defer rl.Stop()
+
+	first := rl.Reserve()
+	second := rl.Reserve()
+	if !first.OK() || !second.OK() {
+		t.Fatal("expected reservations to be OK")
+	}
+
+	if d := first.Delay(); d != 100*time.Millisecond {
+		t.Errorf("expected first reservation to wait for the next tick, got %s", d)
+	}
+	if d := second.Delay(); d != 200*time.Millisecond {
+		t.Errorf("expected second reservation to wait for the tick after, got %s", d)
+	}
+
+	// canceled reservations hand their ticks back to the bucket
+	first.Cancel()
+	second.Cancel()
+	clock.Advance(100 * time.Millisecond)
+	if !rl.Wait(waitCtx(t)) {
+		t.Error("expected canceled reservation's tick to refill the bucket")
+	}
+}
+
+func TestRateLimiterReserveAfterStop(t *testing.T) {
+	rl := rlm.NewRateLimiter(context.Background(), time.Second, 2)
+	rl.Stop()
+	rl.Stop()
+
+	if rl.Reserve().OK() {
+		t.Error("expected reservation to fail after Stop")
+	}
+}
+
+func TestRateLimiterSetRateWhileWaiting(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// one token per hour, so waiters only proceed once the rate is raised
+	rl, clock := newFakeRateLimiter(ctx, time.Hour, 1)
+	defer rl.Stop()
+
+	done := make(chan bool)
+	go func() {
+		done <- rl.Wait(ctx)
+	}()
+
+	if err := rl.SetRate(100*time.Millisecond, 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := rl.Config().Interval; got != 10*time.Millisecond {
+		t.Errorf("expected interval of 10ms, got %s", got)
+	}
+
+	// advance until the refill goroutine has picked up the new interval
+	for i := 0; i < 100; i++ {
+		clock.Advance(10 * time.Millisecond)
+		select {
+		case ok := <-done:
+			if !ok {
+				t.Error("expected blocked Wait to acquire a token")
+			}
+			return
+		case <-time.After(time.Millisecond):
+		}
+	}
+	t.Fatal("blocked Wait did not pick up the new rate")
+}
+
+func TestRateLimiterSetBurst(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rl, clock := newFakeRateLimiter(ctx, 50*time.Millisecond, 10)
+	defer rl.Stop()
+
+	if err := rl.SetBurst(2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	o := &recordingObserver{}
+	rl.SetObserver(o)
+
+	for i := 0; i < 5; i++ {
+		tick(t, rl, clock, 5*time.Millisecond, o)
+	}
+
+	count := 0
+	for rl.Allow() {
+		count++
+	}
+	if count != 2 {
+		t.Errorf("expected the bucket to hold 2 tokens, got %d", count)
+	}
+
+	if got := rl.Config().Burst; got != 2 {
+		t.Errorf("expected burst of 2, got %d", got)
+	}
+}
+
+func TestRateLimiterReconfigureInvalid(t *testing.T) {
+	rl := rlm.NewRateLimiter(context.Background(), time.Second, 5)
+	defer rl.Stop()
+
+	tests := []struct {
+		name string
+		fn   func() error
+	}{
+		{
+			name: "zero intervals",
+			fn:   func() error { return rl.SetRate(time.Second, 0) },
+		},
+		{
+			name: "zero time span",
+			fn:   func() error { return rl.SetRate(0, 5) },
+		},
+		{
+			name: "zero burst",
+			fn:   func() error { return rl.SetBurst(0) },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.fn(); !errors.Is(err, rlm.ErrInvalidLimit) {
+				t.Errorf("expected ErrInvalidLimit, got %v", err)
+			}
+		})
+	}
+
+	expected := rlm.RateLimiterConfig{Interval: 200 * time.Millisecond, Burst: 5}
+	if got := rl.Config(); got != expected {
+		t.Errorf("expected settings to be unchanged %+v, got %+v", expected, got)
+	}
+}
+
+func TestRateLimiterWaitN(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	rl, clock := newFakeRateLimiter(ctx, 30*time.Millisecond, 3)
+	defer rl.Stop()
+
+	if err := rl.WaitN(ctx, 4); !errors.Is(err, rlm.ErrExceedsBurst) {
+		t.Errorf("expected ErrExceedsBurst, got %v", err)
+	}
+	if err := rl.WaitN(ctx, 0); !errors.Is(err, rlm.ErrInvalidTokens) {
+		t.Errorf("expected ErrInvalidTokens, got %v", err)
+	}
+
+	o := &recordingObserver{}
+	rl.SetObserver(o)
+	for i := 0; i < 3; i++ {
+		tick(t, rl, clock, 10*time.Millisecond, o)
+	}
+
+	if err := rl.WaitN(waitCtx(t), 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rl.Allow() {
+		t.Error("expected WaitN to take every token")
+	}
+}
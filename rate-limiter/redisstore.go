@@ -0,0 +1,232 @@
+package ratelimiter
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	RedisDialTimeoutDuration = 5 * time.Second
+	RedisIOTimeoutDuration   = 2 * time.Second
+)
+
+// RedisStore is a Store backed by any server speaking the Redis protocol (RESP),
+// such as Redis, Valkey or KeyDB.
+// Commands are sent over a single connection which is re-established after a failure.
+type RedisStore struct {
+	addr     string
+	password string
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedisStore creates a store talking to the server at addr (host:port).
+// An empty password skips authentication. The connection is opened on first use.
+func NewRedisStore(addr, password string) *RedisStore {
+	return &RedisStore{
+		addr:     addr,
+		password: password,
+	}
+}
+
+// IncrBy adds delta to the counter at key with INCRBY. The counter is first created
+// with its expiry by SET with NX and PX if it does not exist, so a counter never
+// outlives its ttl even if the connection fails between the two commands.
+func (rs *RedisStore) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if ttl > 0 {
+		ms := max(ttl.Milliseconds(), 1)
+		if _, err := rs.do(ctx, "SET", key, "0", "PX", strconv.FormatInt(ms, 10), "NX"); err != nil {
+			return 0, err
+		}
+	}
+
+	reply, err := rs.do(ctx, "INCRBY", key, strconv.FormatInt(delta, 10))
+	if err != nil {
+		return 0, err
+	}
+
+	value, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("unexpected INCRBY reply: %v", reply)
+	}
+	return value, nil
+}
+
+// Get returns the value of the counter at key with GET, or 0 if it does not exist.
+func (rs *RedisStore) Get(ctx context.Context, key string) (int64, error) {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	reply, err := rs.do(ctx, "GET", key)
+	if err != nil {
+		return 0, err
+	}
+
+	switch v := reply.(type) {
+	case nil:
+		return 0, nil
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	default:
+		return 0, fmt.Errorf("unexpected GET reply: %v", reply)
+	}
+}
+
+// Close closes the connection to the server.
+func (rs *RedisStore) Close() error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.conn == nil {
+		return nil
+	}
+
+	err := rs.conn.Close()
+	rs.conn = nil
+	rs.rd = nil
+	return err
+}
+
+// do sends a command and reads its reply, connecting first if needed.
+// The connection is dropped on any I/O error. Must be called with mu held.
+func (rs *RedisStore) do(ctx context.Context, args ...string) (any, error) {
+	if rs.conn == nil {
+		if err := rs.connect(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := rs.roundTrip(ctx, args...)
+	if err != nil {
+		var replyErr redisError
+		if !errors.As(err, &replyErr) {
+			rs.conn.Close()
+			rs.conn = nil
+			rs.rd = nil
+		}
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// connect dials the server and authenticates. Must be called with mu held.
+func (rs *RedisStore) connect(ctx context.Context) error {
+	dialer := net.Dialer{Timeout: RedisDialTimeoutDuration}
+	conn, err := dialer.DialContext(ctx, "tcp", rs.addr)
+	if err != nil {
+		return fmt.Errorf("connect to redis: %w", err)
+	}
+
+	rs.conn = conn
+	rs.rd = bufio.NewReader(conn)
+
+	if rs.password != "" {
+		if _, err := rs.roundTrip(ctx, "AUTH", rs.password); err != nil {
+			rs.conn.Close()
+			rs.conn = nil
+			rs.rd = nil
+			return fmt.Errorf("authenticate to redis: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (rs *RedisStore) roundTrip(ctx context.Context, args ...string) (any, error) {
+	deadline := time.Now().Add(RedisIOTimeoutDuration)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	if err := rs.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if _, err := rs.conn.Write(encodeCommand(args)); err != nil {
+		return nil, err
+	}
+
+	return readReply(rs.rd)
+}
+
+// redisError is an error reply sent by the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+// encodeCommand encodes a command as a RESP array of bulk strings.
+func encodeCommand(args []string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return []byte(b.String())
+}
+
+// readReply reads a single RESP reply. Simple and bulk strings are returned as string,
+// integers as int64, null bulk strings and arrays as nil, and arrays as []any.
+func readReply(rd *bufio.Reader) (any, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("malformed redis reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			return nil, nil
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = readReply(rd); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unknown redis reply type %q", line[0])
+	}
+}
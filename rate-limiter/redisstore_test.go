@@ -0,0 +1,218 @@
+package ratelimiter_test
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+// fakeRedis is a local stand-in speaking just enough of the Redis protocol for RedisStore.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+
+	mu   sync.Mutex
+	data map[string]int64
+	ttls map[string]time.Duration
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	fr := &fakeRedis{
+		ln:       ln,
+		password: password,
+		data:     make(map[string]int64),
+		ttls:     make(map[string]time.Duration),
+	}
+	go fr.serve()
+	t.Cleanup(func() { ln.Close() })
+
+	return fr
+}
+
+func (fr *fakeRedis) serve() {
+	for {
+		conn, err := fr.ln.Accept()
+		if err != nil {
+			return
+		}
+		go fr.handle(conn)
+	}
+}
+
+func (fr *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+
+	rd := bufio.NewReader(conn)
+	authed := fr.password == ""
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+
+		if !authed && strings.ToUpper(args[0]) != "AUTH" {
+			io.WriteString(conn, "-NOAUTH Authentication required.\r\n")
+			continue
+		}
+
+		fr.mu.Lock()
+		switch strings.ToUpper(args[0]) {
+		case "AUTH":
+			if args[1] == fr.password {
+				authed = true
+				io.WriteString(conn, "+OK\r\n")
+			} else {
+				io.WriteString(conn, "-WRONGPASS invalid password\r\n")
+			}
+		case "INCRBY":
+			delta, _ := strconv.ParseInt(args[2], 10, 64)
+			fr.data[args[1]] += delta
+			fmt.Fprintf(conn, ":%d\r\n", fr.data[args[1]])
+		case "SET":
+			// only the form used by RedisStore: SET key value PX ms NX
+			if _, ok := fr.data[args[1]]; ok {
+				io.WriteString(conn, "$-1\r\n")
+				break
+			}
+			fr.data[args[1]], _ = strconv.ParseInt(args[2], 10, 64)
+			ms, _ := strconv.ParseInt(args[4], 10, 64)
+			fr.ttls[args[1]] = time.Duration(ms) * time.Millisecond
+			io.WriteString(conn, "+OK\r\n")
+		case "GET":
+			if v, ok := fr.data[args[1]]; ok {
+				s := strconv.FormatInt(v, 10)
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(s), s)
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		fr.mu.Unlock()
+	}
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		if _, err := rd.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	tests := []struct {
+		name      string
+		password  string
+		auth      string
+		expectErr bool
+	}{
+		{
+			name: "no auth",
+		},
+		{
+			name:     "with auth",
+			password: "secret",
+			auth:     "secret",
+		},
+		{
+			name:      "wrong password",
+			password:  "secret",
+			auth:      "wrong",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fr := newFakeRedis(t, tt.password)
+			rs := rlm.NewRedisStore(fr.ln.Addr().String(), tt.auth)
+			defer rs.Close()
+
+			ctx := context.Background()
+			v, err := rs.IncrBy(ctx, "k", 3, time.Minute)
+			if tt.expectErr {
+				if err == nil {
+					t.Fatal("expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if v != 3 {
+				t.Errorf("expected 3, got %d", v)
+			}
+
+			if v, _ = rs.IncrBy(ctx, "k", -1, time.Minute); v != 2 {
+				t.Errorf("expected 2, got %d", v)
+			}
+			if v, _ = rs.Get(ctx, "k"); v != 2 {
+				t.Errorf("expected 2, got %d", v)
+			}
+			if v, _ = rs.Get(ctx, "missing"); v != 0 {
+				t.Errorf("expected 0 for missing key, got %d", v)
+			}
+
+			fr.mu.Lock()
+			ttl := fr.ttls["k"]
+			fr.mu.Unlock()
+			if ttl != time.Minute {
+				t.Errorf("expected ttl of %s to be set once on creation, got %s", time.Minute, ttl)
+			}
+		})
+	}
+}
+
+func TestRedisStoreSharedQuota(t *testing.T) {
+	fr := newFakeRedis(t, "")
+
+	count := 0
+	for r := 0; r < 3; r++ {
+		rs := rlm.NewRedisStore(fr.ln.Addr().String(), "")
+		defer rs.Close()
+
+		dl := rlm.NewDistributedLimiter(rs, "scraper", 4, time.Hour)
+		defer dl.Stop()
+
+		for i := 0; i < 3; i++ {
+			if dl.Allow() {
+				count++
+			}
+		}
+	}
+
+	if count != 4 {
+		t.Errorf("expected 4 allowed actions across replicas, got %d", count)
+	}
+}
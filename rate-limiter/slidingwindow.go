@@ -0,0 +1,333 @@
+package ratelimiter
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// SlidingWindowLog allows `limit` actions in any rolling window of time.
+// It keeps the timestamp of every admitted action, which makes it exact
+// at the cost of memory proportional to `limit`.
+type SlidingWindowLog struct {
+	mu     sync.Mutex
+	clock  Clock
+	limit  int
+	window time.Duration
+	log    []time.Time // sorted times of admitted and reserved actions
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSlidingWindowLog creates a limiter allowing `limit` actions in any rolling `window`.
+func NewSlidingWindowLog(limit int, window time.Duration) *SlidingWindowLog {
+	return &SlidingWindowLog{
+		clock:  SystemClock,
+		limit:  limit,
+		window: window,
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Wait blocks until the rolling window has room or the context is done.
+// Returns true if an action was admitted, false if context was canceled or the limiter was stopped.
+func (sl *SlidingWindowLog) Wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	return waitFor(ctx, sl.Reserve(), sl.stopCh) == nil
+}
+
+// Allow reports whether the rolling window has room right now, logging the action if so.
+func (sl *SlidingWindowLog) Allow() bool {
+	if sl.limit <= 0 || sl.stopped() {
+		return false
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	now := sl.clock.Now()
+	sl.prune(now)
+	if sl.earliest(now).After(now) {
+		return false
+	}
+	sl.insert(now)
+	return true
+}
+
+// Reserve logs an action at the earliest time the rolling window has room
+// and reports how long until then.
+func (sl *SlidingWindowLog) Reserve() *Reservation {
+	if sl.limit <= 0 || sl.stopped() {
+		return &Reservation{}
+	}
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	now := sl.clock.Now()
+	sl.prune(now)
+	timeToAct := sl.earliest(now)
+	sl.insert(timeToAct)
+
+	return &Reservation{
+		ok:        true,
+		clock:     sl.clock,
+		timeToAct: timeToAct,
+		cancel:    func() { sl.release(timeToAct) },
+	}
+}
+
+// SetClock makes the limiter use c as its source of time, e.g. a FakeClock in tests.
+func (sl *SlidingWindowLog) SetClock(c Clock) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sl.clock = c
+}
+
+func (sl *SlidingWindowLog) currentClock() Clock {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	return sl.clock
+}
+
+// Stop stops the limiter. Blocked and future callers of Wait return immediately.
+func (sl *SlidingWindowLog) Stop() {
+	sl.stopOnce.Do(func() {
+		close(sl.stopCh)
+	})
+}
+
+func (sl *SlidingWindowLog) stopped() bool {
+	select {
+	case <-sl.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// earliest returns the first time at or after now when one more action fits
+// in the rolling window. Must be called with mu held.
+func (sl *SlidingWindowLog) earliest(now time.Time) time.Time {
+	if len(sl.log) < sl.limit {
+		return now
+	}
+
+	t := sl.log[len(sl.log)-sl.limit].Add(sl.window)
+	if t.Before(now) {
+		return now
+	}
+	return t
+}
+
+// prune drops actions which have left the rolling window. Must be called with mu held.
+func (sl *SlidingWindowLog) prune(now time.Time) {
+	cutoff := now.Add(-sl.window)
+	i := sort.Search(len(sl.log), func(i int) bool {
+		return sl.log[i].After(cutoff)
+	})
+	sl.log = sl.log[i:]
+}
+
+// insert adds t to the log, keeping it sorted. Must be called with mu held.
+func (sl *SlidingWindowLog) insert(t time.Time) {
+	i := sort.Search(len(sl.log), func(i int) bool {
+		return sl.log[i].After(t)
+	})
+	sl.log = append(sl.log, time.Time{})
+	copy(sl.log[i+1:], sl.log[i:])
+	sl.log[i] = t
+}
+
+func (sl *SlidingWindowLog) release(t time.Time) {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	for i, ts := range sl.log {
+		if ts.Equal(t) {
+			sl.log = append(sl.log[:i], sl.log[i+1:]...)
+			return
+		}
+	}
+}
+
+// SlidingWindowCounter approximates a rolling window using the counts of the
+// current and previous fixed windows, weighting the previous count by how much
+// of it still overlaps the rolling window. It needs constant memory regardless of `limit`.
+type SlidingWindowCounter struct {
+	mu     sync.Mutex
+	clock  Clock
+	limit  int
+	window time.Duration
+	counts map[int64]int // actions counted per window index, including reserved future windows
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// NewSlidingWindowCounter creates a limiter allowing approximately `limit` actions in any rolling `window`.
+func NewSlidingWindowCounter(limit int, window time.Duration) *SlidingWindowCounter {
+	return &SlidingWindowCounter{
+		clock:  SystemClock,
+		limit:  limit,
+		window: window,
+		counts: make(map[int64]int),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Wait blocks until the estimated rolling count has room or the context is done.
+// Returns true if an action was admitted, false if context was canceled or the limiter was stopped.
+func (sc *SlidingWindowCounter) Wait(ctx context.Context) bool {
+	select {
+	case <-ctx.Done():
+		return false
+	default:
+	}
+
+	return waitFor(ctx, sc.Reserve(), sc.stopCh) == nil
+}
+
+// Allow reports whether the estimated rolling count has room right now, counting the action if so.
+func (sc *SlidingWindowCounter) Allow() bool {
+	if sc.limit <= 0 || sc.window <= 0 || sc.stopped() {
+		return false
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := sc.clock.Now()
+	idx := sc.prune(now)
+	if !sc.earliestIn(idx, now).Equal(now) {
+		return false
+	}
+	sc.counts[idx]++
+	return true
+}
+
+// Reserve counts an action at the earliest time the estimated rolling count has room
+// and reports how long until then.
+func (sc *SlidingWindowCounter) Reserve() *Reservation {
+	if sc.limit <= 0 || sc.window <= 0 || sc.stopped() {
+		return &Reservation{}
+	}
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	now := sc.clock.Now()
+	w := sc.prune(now)
+	timeToAct := sc.earliestIn(w, now)
+	for timeToAct.IsZero() {
+		w++
+		timeToAct = sc.earliestIn(w, now)
+	}
+	sc.counts[w]++
+
+	return &Reservation{
+		ok:        true,
+		clock:     sc.clock,
+		timeToAct: timeToAct,
+		cancel:    func() { sc.release(w) },
+	}
+}
+
+// SetClock makes the limiter use c as its source of time, e.g. a FakeClock in tests.
+func (sc *SlidingWindowCounter) SetClock(c Clock) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	sc.clock = c
+}
+
+func (sc *SlidingWindowCounter) currentClock() Clock {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	return sc.clock
+}
+
+// Stop stops the limiter. Blocked and future callers of Wait return immediately.
+func (sc *SlidingWindowCounter) Stop() {
+	sc.stopOnce.Do(func() {
+		close(sc.stopCh)
+	})
+}
+
+func (sc *SlidingWindowCounter) stopped() bool {
+	select {
+	case <-sc.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// earliestIn returns the first time within window w, and not before now, at which
+// one more action keeps the estimated count within limit. Returns the zero time if
+// window w has no such time. Must be called with mu held.
+func (sc *SlidingWindowCounter) earliestIn(w int64, now time.Time) time.Time {
+	return earliestInWindow(windowStart(w, sc.window), sc.window, sc.limit, sc.counts[w-1], sc.counts[w], now)
+}
+
+// earliestInWindow returns the first time within the window starting at start, and not
+// before now, at which one more action keeps the sliding window estimate within limit.
+// prev and curr are the counts of the previous and the given window.
+// Returns the zero time if the window has no such time.
+func earliestInWindow(start time.Time, window time.Duration, limit, prev, curr int, now time.Time) time.Time {
+	if curr+1 > limit {
+		return time.Time{}
+	}
+
+	end := start.Add(window)
+	t := start
+	if now.After(t) {
+		t = now
+	}
+
+	// the estimate is prev*(1-f) + curr, where f is the elapsed fraction of the window
+	if prev > 0 {
+		f := 1 - float64(limit-curr-1)/float64(prev)
+		if f > 0 {
+			if at := start.Add(time.Duration(f * float64(window))); at.After(t) {
+				t = at
+			}
+		}
+	}
+
+	if !t.Before(end) {
+		return time.Time{}
+	}
+	return t
+}
+
+// prune drops the counts of windows which no longer affect the estimate
+// and returns the current window index. Must be called with mu held.
+func (sc *SlidingWindowCounter) prune(now time.Time) int64 {
+	idx := windowIndex(now, sc.window)
+	for w := range sc.counts {
+		if w < idx-1 {
+			delete(sc.counts, w)
+		}
+	}
+	return idx
+}
+
+func (sc *SlidingWindowCounter) release(w int64) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if sc.counts[w] > 0 {
+		sc.counts[w]--
+	}
+}
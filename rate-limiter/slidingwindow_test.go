@@ -0,0 +1,132 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func TestSlidingWindowAllow(t *testing.T) {
+	tests := []struct {
+		name        string
+		limiter     rlm.Limiter
+		attempts    int
+		expectCount int
+	}{
+		{
+			name:        "log under the limit",
+			limiter:     rlm.NewSlidingWindowLog(5, time.Hour),
+			attempts:    3,
+			expectCount: 3,
+		},
+		{
+			name:        "log over the limit",
+			limiter:     rlm.NewSlidingWindowLog(5, time.Hour),
+			attempts:    8,
+			expectCount: 5,
+		},
+		{
+			name:        "counter under the limit",
+			limiter:     rlm.NewSlidingWindowCounter(5, time.Hour),
+			attempts:    3,
+			expectCount: 3,
+		},
+		{
+			name:        "counter over the limit",
+			limiter:     rlm.NewSlidingWindowCounter(5, time.Hour),
+			attempts:    8,
+			expectCount: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer tt.limiter.Stop()
+			tt.limiter.(interface{ SetClock(rlm.Clock) }).SetClock(rlm.NewFakeClock(epoch))
+
+			count := 0
+			for i := 0; i < tt.attempts; i++ {
+				if tt.limiter.Allow() {
+					count++
+				}
+			}
+
+			if count != tt.expectCount {
+				t.Errorf("expected %d allowed actions, got %d", tt.expectCount, count)
+			}
+		})
+	}
+}
+
+func TestSlidingWindowLogRolls(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	sl := rlm.NewSlidingWindowLog(2, time.Minute)
+	sl.SetClock(clock)
+	defer sl.Stop()
+
+	sl.Allow()
+	clock.Advance(30 * time.Second)
+	sl.Allow()
+
+	// the third action has to wait for the first to leave the rolling window
+	if d := sl.Reserve().Delay(); d != 30*time.Second {
+		t.Errorf("expected to wait for the first action to roll off, got %s", d)
+	}
+	// the fourth waits for the second
+	if d := sl.Reserve().Delay(); d != time.Minute {
+		t.Errorf("expected to wait for the second action to roll off, got %s", d)
+	}
+}
+
+func TestSlidingWindowLogReserveCancel(t *testing.T) {
+	sl := rlm.NewSlidingWindowLog(1, time.Hour)
+	sl.SetClock(rlm.NewFakeClock(epoch))
+	defer sl.Stop()
+
+	r := sl.Reserve()
+	if !r.OK() || r.Delay() != 0 {
+		t.Fatalf("expected immediate reservation, got ok=%v delay=%s", r.OK(), r.Delay())
+	}
+
+	r.Cancel()
+	if !sl.Allow() {
+		t.Error("expected canceled reservation to free its slot")
+	}
+}
+
+func TestSlidingWindowCounterEstimate(t *testing.T) {
+	clock := rlm.NewFakeClock(epoch)
+	sc := rlm.NewSlidingWindowCounter(4, time.Minute)
+	sc.SetClock(clock)
+	defer sc.Stop()
+
+	for i := 0; i < 4; i++ {
+		sc.Allow()
+	}
+
+	// a quarter into the next window the estimate is 4*0.75 = 3, leaving room for one action
+	clock.Advance(75 * time.Second)
+	if !sc.Allow() {
+		t.Error("expected room for one action")
+	}
+	if sc.Allow() {
+		t.Error("expected the estimate to be at the limit")
+	}
+
+	// the next slot opens once the previous window's weight drops to 2, halfway through
+	if d := sc.Reserve().Delay(); d != 15*time.Second {
+		t.Errorf("expected delay of 15s, got %s", d)
+	}
+
+	done := make(chan bool)
+	go func() {
+		done <- sc.Wait(context.Background())
+	}()
+	clock.BlockUntil(1)
+	clock.Advance(time.Minute)
+	if !<-done {
+		t.Error("expected Wait to be admitted once the window rolls")
+	}
+}
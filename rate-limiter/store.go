@@ -0,0 +1,87 @@
+package ratelimiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Store keeps rate limiting counters. Sharing a store between processes
+// lets them draw from a single quota.
+type Store interface {
+	// IncrBy atomically adds delta to the counter at key and returns the new value.
+	// A counter created by the call expires after ttl.
+	IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// Get returns the value of the counter at key, or 0 if it does not exist.
+	Get(ctx context.Context, key string) (int64, error)
+}
+
+var (
+	_ Store = (*MemoryStore)(nil)
+	_ Store = (*RedisStore)(nil)
+)
+
+// MemoryStore is a Store held in process memory.
+// It is useful for tests and for sharing one quota between limiters of the same process.
+type MemoryStore struct {
+	mu       sync.Mutex
+	clock    Clock
+	counters map[string]memoryCounter
+}
+
+type memoryCounter struct {
+	value   int64
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		clock:    SystemClock,
+		counters: make(map[string]memoryCounter),
+	}
+}
+
+// SetClock makes counters expire by c, e.g. a FakeClock in tests.
+func (ms *MemoryStore) SetClock(c Clock) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.clock = c
+}
+
+// IncrBy adds delta to the counter at key and returns the new value.
+func (ms *MemoryStore) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	now := ms.clock.Now()
+	ms.expire(now)
+
+	c, ok := ms.counters[key]
+	if !ok {
+		c.expires = now.Add(ttl)
+	}
+	c.value += delta
+	ms.counters[key] = c
+
+	return c.value, nil
+}
+
+// Get returns the value of the counter at key, or 0 if it does not exist.
+func (ms *MemoryStore) Get(ctx context.Context, key string) (int64, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	ms.expire(ms.clock.Now())
+	return ms.counters[key].value, nil
+}
+
+// expire drops counters past their ttl. Must be called with mu held.
+func (ms *MemoryStore) expire(now time.Time) {
+	for key, c := range ms.counters {
+		if !c.expires.After(now) {
+			delete(ms.counters, key)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrExceedsBurst is returned when more tokens are requested at once than the bucket can hold.
+	ErrExceedsBurst = errors.New("requested tokens exceed burst size")
+	// ErrLimiterStopped is returned when the limiter is stopped before tokens could be acquired.
+	ErrLimiterStopped = errors.New("rate limiter stopped")
+	// ErrInvalidTokens is returned when zero or a negative number of tokens is requested.
+	ErrInvalidTokens = errors.New("requested tokens must be positive")
+)
+
+// TokenBucket is a rate limiter which refills tokens at a steady rate up to a burst capacity.
+// Unlike RateLimiter, the sustained rate and the burst size are configured independently,
+// and callers may acquire several tokens at once.
+type TokenBucket struct {
+	mu     sync.Mutex
+	clock  Clock
+	rate   float64 // tokens added per second
+	burst  int
+	tokens float64
+	last   time.Time
+
+	observer Observer
+	overflow float64 // fraction of a token discarded but not yet reported as a drop
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+}
+
+// TokenBucketConfig describes the current settings of a TokenBucket.
+type TokenBucketConfig struct {
+	// Rate is the number of tokens added per second.
+	Rate float64
+	// Burst is the maximum number of tokens the bucket holds.
+	Burst int
+}
+
+// NewTokenBucket creates a token bucket which refills `limit` tokens per `per`
+// and holds at most `burst` tokens. The bucket starts full.
+// e.g. NewTokenBucket(100, time.Minute, 10) allows 100 actions per minute in bursts of up to 10.
+// It panics if limit or per is not positive, as the bucket would never refill.
+func NewTokenBucket(limit int, per time.Duration, burst int) *TokenBucket {
+	if limit <= 0 || per <= 0 {
+		panic("non-positive limit or period for NewTokenBucket")
+	}
+	return newTokenBucket(float64(limit)/per.Seconds(), burst)
+}
+
+// newTokenBucket creates a full token bucket adding rate tokens per second.
+func newTokenBucket(rate float64, burst int) *TokenBucket {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &TokenBucket{
+		rate:   rate,
+		burst:  burst,
+		clock:  SystemClock,
+		tokens: float64(burst),
+		last:   SystemClock.Now(),
+		stopCh: make(chan struct{}),
+	}
+}
+
+// Wait blocks until a token is available or the context is done.
+// Returns true if a token was acquired, false if context was canceled or the bucket was stopped.
+func (tb *TokenBucket) Wait(ctx context.Context) bool {
+	return tb.WaitN(ctx, 1) == nil
+}
+
+// WaitN blocks until n tokens are available or the context is done.
+// Returns ErrInvalidTokens if n is not positive.
+// Tokens are handed out in the order they were requested, so a large request
+// is not starved by a stream of smaller ones.
+func (tb *TokenBucket) WaitN(ctx context.Context, n int) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	default:
+	}
+
+	r, err := tb.reserveN(n)
+	if err != nil {
+		return err
+	}
+	return waitFor(ctx, r, tb.stopCh)
+}
+
+// Allow reports whether a token is available right now, consuming it if so.
+func (tb *TokenBucket) Allow() bool {
+	return tb.AllowN(1)
+}
+
+// AllowN reports whether n tokens are available right now, consuming them if so.
+// It reports false if n is not positive.
+func (tb *TokenBucket) AllowN(n int) bool {
+	if n <= 0 || tb.stopped() {
+		return false
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.advance(tb.clock.Now())
+	if tb.tokens < float64(n) {
+		return false
+	}
+	tb.tokens -= float64(n)
+	return true
+}
+
+// Reserve claims a token and reports how long until it may be used.
+func (tb *TokenBucket) Reserve() *Reservation {
+	return tb.ReserveN(1)
+}
+
+// ReserveN claims n tokens and reports how long until they may be used.
+// The reservation is not OK if n is not positive, exceeds the burst size or the bucket is stopped.
+func (tb *TokenBucket) ReserveN(n int) *Reservation {
+	r, err := tb.reserveN(n)
+	if err != nil {
+		return &Reservation{}
+	}
+	return r
+}
+
+// SetRate changes the bucket to refill `limit` tokens per `per`, like NewTokenBucket.
+// Tokens accrued so far are kept; reservations already made keep their delay.
+func (tb *TokenBucket) SetRate(limit int, per time.Duration) error {
+	if limit <= 0 || per <= 0 {
+		return ErrInvalidLimit
+	}
+
+	tb.setRate(float64(limit) / per.Seconds())
+	return nil
+}
+
+// SetBurst changes the maximum number of tokens the bucket holds.
+// Tokens held beyond the new burst size are dropped.
+func (tb *TokenBucket) SetBurst(burst int) error {
+	if burst <= 0 {
+		return ErrInvalidLimit
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.advance(tb.clock.Now())
+	tb.burst = burst
+	tb.clamp()
+	return nil
+}
+
+// Config returns the bucket's current settings.
+func (tb *TokenBucket) Config() TokenBucketConfig {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	return TokenBucketConfig{
+		Rate:  tb.rate,
+		Burst: tb.burst,
+	}
+}
+
+func (tb *TokenBucket) reserveN(n int) (*Reservation, error) {
+	if n <= 0 {
+		return nil, ErrInvalidTokens
+	}
+	if tb.stopped() {
+		return nil, ErrLimiterStopped
+	}
+
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	if n > tb.burst {
+		return nil, ErrExceedsBurst
+	}
+
+	now := tb.clock.Now()
+	tb.advance(now)
+	if tb.rate == 0 && tb.tokens < float64(n) {
+		return &Reservation{}, nil
+	}
+
+	tb.tokens -= float64(n)
+	return &Reservation{
+		ok:        true,
+		clock:     tb.clock,
+		timeToAct: now.Add(tb.durationFor(-tb.tokens)),
+		cancel:    func() { tb.restore(n) },
+	}, nil
+}
+
+// Stop stops the token bucket. Blocked and future callers of Wait return immediately without a token.
+func (tb *TokenBucket) Stop() {
+	tb.stopOnce.Do(func() {
+		close(tb.stopCh)
+	})
+}
+
+// Level returns the number of tokens in the bucket and its burst size.
+// Tokens promised to outstanding reservations are not counted.
+func (tb *TokenBucket) Level() (float64, int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.advance(tb.clock.Now())
+	return max(tb.tokens, 0), tb.burst
+}
+
+// SetObserver makes the bucket report tokens dropped because it is full to o,
+// once per whole token. Use Observe to also report waits, acquisitions and rejections.
+func (tb *TokenBucket) SetObserver(o Observer) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.observer = o
+}
+
+// SetClock makes the bucket use c as its source of time, e.g. a FakeClock in tests.
+func (tb *TokenBucket) SetClock(c Clock) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.clock = c
+	tb.last = c.Now()
+}
+
+func (tb *TokenBucket) currentClock() Clock {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	return tb.clock
+}
+
+// setRate changes the refill rate, keeping the tokens accrued at the previous rate.
+func (tb *TokenBucket) setRate(rate float64) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.advance(tb.clock.Now())
+	tb.rate = rate
+}
+
+func (tb *TokenBucket) stopped() bool {
+	select {
+	case <-tb.stopCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// advance adds the tokens accrued since the last update. Must be called with mu held.
+func (tb *TokenBucket) advance(now time.Time) {
+	elapsed := now.Sub(tb.last)
+	if elapsed <= 0 {
+		return
+	}
+	tb.last = now
+
+	tb.tokens += elapsed.Seconds() * tb.rate
+	tb.clamp()
+}
+
+// clamp discards tokens beyond the burst size, reporting the whole tokens to the observer.
+// Must be called with mu held.
+func (tb *TokenBucket) clamp() {
+	if tb.tokens <= float64(tb.burst) {
+		return
+	}
+
+	excess := tb.tokens - float64(tb.burst)
+	tb.tokens = float64(tb.burst)
+	if tb.observer == nil {
+		return
+	}
+
+	tb.overflow += excess
+	dropped := math.Floor(tb.overflow)
+	tb.overflow -= dropped
+	if dropped >= 1 {
+		// a bucket left idle at a high rate can overflow by more tokens than an int holds
+		tb.observer.OnDrop(int(min(dropped, math.MaxInt32)))
+	}
+}
+
+// durationFor returns the time needed to accrue the given number of tokens.
+func (tb *TokenBucket) durationFor(tokens float64) time.Duration {
+	if tokens <= 0 || tb.rate == 0 {
+		return 0
+	}
+	return time.Duration(tokens / tb.rate * float64(time.Second))
+}
+
+// restore gives back tokens taken by a canceled reservation.
+func (tb *TokenBucket) restore(n int) {
+	tb.mu.Lock()
+	defer tb.mu.Unlock()
+
+	tb.advance(tb.clock.Now())
+	tb.tokens += float64(n)
+	tb.clamp()
+}
@@ -0,0 +1,287 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+func newFakeTokenBucket(limit int, per time.Duration, burst int) (*rlm.TokenBucket, *rlm.FakeClock) {
+	clock := rlm.NewFakeClock(epoch)
+	tb := rlm.NewTokenBucket(limit, per, burst)
+	tb.SetClock(clock)
+	return tb, clock
+}
+
+func TestTokenBucketBurst(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		per   time.Duration
+		burst int
+	}{
+		{
+			name:  "burst of 3 at 10 per second",
+			limit: 10,
+			per:   time.Second,
+			burst: 3,
+		},
+		{
+			name:  "burst of 10 at 100 per minute",
+			limit: 100,
+			per:   time.Minute,
+			burst: 10,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb, _ := newFakeTokenBucket(tt.limit, tt.per, tt.burst)
+			defer tb.Stop()
+
+			count := 0
+			for tb.Allow() {
+				count++
+			}
+
+			if count != tt.burst {
+				t.Errorf("expected %d immediate actions, got %d", tt.burst, count)
+			}
+		})
+	}
+}
+
+func TestTokenBucketRefill(t *testing.T) {
+	tb, clock := newFakeTokenBucket(20, time.Second, 1)
+	defer tb.Stop()
+
+	if !tb.Allow() {
+		t.Fatal("expected the first token to be immediate")
+	}
+
+	r := tb.Reserve()
+	if d := r.Delay(); d != 50*time.Millisecond {
+		t.Errorf("expected the next token in 50ms, got %s", d)
+	}
+
+	clock.Advance(49 * time.Millisecond)
+	if tb.Allow() {
+		t.Error("expected no token before the refill")
+	}
+	clock.Advance(time.Millisecond)
+	if r.Delay() != 0 {
+		t.Errorf("expected reservation to be ready, got %s", r.Delay())
+	}
+}
+
+func TestTokenBucketHighRateIdle(t *testing.T) {
+	tb, clock := newFakeTokenBucket(1_000_000, time.Millisecond, 10)
+	defer tb.Stop()
+
+	// a long idle period at a high rate overflows the bucket by far more tokens than a float64 counts exactly
+	clock.Advance(24 * time.Hour)
+	for i := 0; i < 10; i++ {
+		if !tb.Allow() {
+			t.Fatalf("expected token %d of the burst to be available", i+1)
+		}
+	}
+}
+
+func TestTokenBucketWaitN(t *testing.T) {
+	tests := []struct {
+		name      string
+		burst     int
+		n         int
+		expectErr error
+	}{
+		{
+			name:  "n within burst",
+			burst: 5,
+			n:     5,
+		},
+		{
+			name:      "n exceeds burst",
+			burst:     5,
+			n:         6,
+			expectErr: rlm.ErrExceedsBurst,
+		},
+		{
+			name:      "zero tokens",
+			burst:     5,
+			n:         0,
+			expectErr: rlm.ErrInvalidTokens,
+		},
+		{
+			name:      "negative tokens",
+			burst:     5,
+			n:         -1,
+			expectErr: rlm.ErrInvalidTokens,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tb, _ := newFakeTokenBucket(1, time.Second, tt.burst)
+			defer tb.Stop()
+
+			err := tb.WaitN(context.Background(), tt.n)
+			if !errors.Is(err, tt.expectErr) {
+				t.Errorf("expected error %v, got %v", tt.expectErr, err)
+			}
+		})
+	}
+}
+
+func TestTokenBucketAllowNRejectsNonPositive(t *testing.T) {
+	tb, _ := newFakeTokenBucket(1, time.Second, 2)
+	defer tb.Stop()
+
+	if tb.AllowN(0) || tb.AllowN(-1) {
+		t.Error("expected non-positive requests to be refused")
+	}
+	if tb.ReserveN(-1).OK() {
+		t.Error("expected a reservation of negative tokens not to be OK")
+	}
+
+	// a refused request must not have added tokens to the bucket
+	if level, _ := tb.Level(); level != 2 {
+		t.Errorf("expected 2 tokens, got %v", level)
+	}
+}
+
+func TestNewTokenBucketRejectsNoRefill(t *testing.T) {
+	tests := []struct {
+		name  string
+		limit int
+		per   time.Duration
+	}{
+		{name: "zero limit", limit: 0, per: time.Second},
+		{name: "negative limit", limit: -1, per: time.Second},
+		{name: "zero period", limit: 1, per: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic for a bucket which never refills")
+				}
+			}()
+			rlm.NewTokenBucket(tt.limit, tt.per, 1)
+		})
+	}
+}
+
+func TestTokenBucketWaitBlocksUntilRefill(t *testing.T) {
+	tb, clock := newFakeTokenBucket(1, time.Second, 2)
+	defer tb.Stop()
+
+	if err := tb.WaitN(context.Background(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		done <- tb.WaitN(context.Background(), 2)
+	}()
+
+	clock.BlockUntil(1)
+	clock.Advance(time.Second)
+	select {
+	case <-done:
+		t.Fatal("expected WaitN to need two refills")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	clock.Advance(time.Second)
+	if err := <-done; err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestTokenBucketCancelRestoresTokens(t *testing.T) {
+	tb, clock := newFakeTokenBucket(1, time.Second, 2)
+	defer tb.Stop()
+
+	if err := tb.WaitN(context.Background(), 2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- tb.WaitN(ctx, 2)
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context canceled, got %v", err)
+	}
+
+	// the canceled wait must not leave the bucket in debt
+	clock.Advance(time.Second)
+	if !tb.Allow() {
+		t.Error("expected token after one refill interval")
+	}
+}
+
+func TestTokenBucketStop(t *testing.T) {
+	tb, clock := newFakeTokenBucket(1, time.Minute, 1)
+	tb.Wait(context.Background())
+
+	done := make(chan bool)
+	go func() {
+		done <- tb.Wait(context.Background())
+	}()
+
+	clock.BlockUntil(1)
+	tb.Stop()
+
+	select {
+	case ok := <-done:
+		if ok {
+			t.Error("expected Wait to fail after Stop")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Wait did not return after Stop")
+	}
+}
+
+func TestTokenBucketReconfigure(t *testing.T) {
+	tb, clock := newFakeTokenBucket(1, time.Hour, 5)
+	defer tb.Stop()
+
+	if err := tb.SetBurst(2); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if tb.AllowN(3) {
+		t.Error("expected request above the new burst to be rejected")
+	}
+	if err := tb.WaitN(context.Background(), 3); !errors.Is(err, rlm.ErrExceedsBurst) {
+		t.Errorf("expected ErrExceedsBurst, got %v", err)
+	}
+
+	if !tb.AllowN(2) {
+		t.Fatal("expected the bucket to hold 2 tokens")
+	}
+
+	if err := tb.SetRate(50, time.Second); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	clock.Advance(20 * time.Millisecond)
+	if !tb.Allow() {
+		t.Error("expected a token at the new rate")
+	}
+
+	expected := rlm.TokenBucketConfig{Rate: 50, Burst: 2}
+	if got := tb.Config(); got != expected {
+		t.Errorf("expected %+v, got %+v", expected, got)
+	}
+
+	if err := tb.SetRate(0, time.Second); !errors.Is(err, rlm.ErrInvalidLimit) {
+		t.Errorf("expected ErrInvalidLimit, got %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+	"golang.org/x/net/html/charset"
+)
+
+// DefaultMaxBodySize is the largest decoded body kept by the default scrape function.
+const DefaultMaxBodySize = 10 << 20
+
+// acceptEncoding is sent with every request. Setting it ourselves stops http.Transport
+// from decoding gzip transparently, so all encodings are handled in one place.
+const acceptEncoding = "gzip, deflate, br"
+
+// readBody decodes the body of resp, keeping at most maxSize bytes of the decoded content.
+// Text, i.e. text/*, HTML, XML and JSON types and any type declaring a charset, is converted
+// to UTF-8; other bodies, e.g. images, are kept byte for byte. Reports whether the body was
+// cut off, and how many bytes of it were read from the connection, before decoding.
+func readBody(resp *http.Response, maxSize int64) (content string, truncated bool, received int64, err error) {
+	counter := &countingReader{r: resp.Body}
+
+	decompressed, err := decompress(counter, resp.Header.Get("Content-Encoding"))
+	if errors.Is(err, io.EOF) {
+		// an empty body is sent as is whatever its Content-Encoding, e.g. in a 204 or 304
+		return "", false, counter.n, nil
+	}
+	if err != nil {
+		return "", false, counter.n, err
+	}
+
+	body := bufio.NewReader(decompressed)
+	contentType := resp.Header.Get("Content-Type")
+	text := textual(contentType)
+	if contentType == "" {
+		// without a Content-Type, tell text from binary by the first bytes
+		head, _ := body.Peek(512)
+		text = textual(http.DetectContentType(head))
+	}
+
+	var decoded io.Reader = body
+	if text {
+		decoded, err = charset.NewReader(body, contentType)
+		if errors.Is(err, io.EOF) {
+			return "", false, counter.n, nil
+		}
+		if err != nil {
+			return "", false, counter.n, fmt.Errorf("decoding charset: %w", err)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := io.Copy(&buf, io.LimitReader(decoded, maxSize+1))
+	if err != nil {
+		return "", false, counter.n, err
+	}
+
+	truncated = n > maxSize
+	if truncated {
+		end := int(maxSize)
+		// cut text at a character boundary so the content stays valid UTF-8
+		for text && end > 0 && !utf8.RuneStart(buf.Bytes()[end]) {
+			end--
+		}
+		buf.Truncate(end)
+	}
+	return buf.String(), truncated, counter.n, nil
+}
+
+// textual reports whether a body of contentType is text: text/*, HTML, XML and JSON types,
+// and any type declaring a charset.
+func textual(contentType string) bool {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if _, ok := params["charset"]; ok {
+		return true
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+xml"),
+		strings.HasSuffix(mediaType, "+json"):
+		return true
+	}
+	return mediaType == "application/xml" || mediaType == "application/json"
+}
+
+// countingReader counts the bytes read through it.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+// decompress wraps r in readers undoing the Content-Encoding, applied in the order listed.
+func decompress(r io.Reader, contentEncoding string) (io.Reader, error) {
+	encodings := strings.Split(contentEncoding, ",")
+	for i := len(encodings) - 1; i >= 0; i-- {
+		var err error
+		switch enc := strings.ToLower(strings.TrimSpace(encodings[i])); enc {
+		case "", "identity":
+		case "gzip", "x-gzip":
+			r, err = gzip.NewReader(r)
+		case "deflate":
+			r, err = newDeflateReader(r)
+		case "br":
+			r = brotli.NewReader(r)
+		default:
+			return nil, fmt.Errorf("unsupported content encoding %q", enc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s body: %w", encodings[i], err)
+		}
+	}
+	return r, nil
+}
+
+// newDeflateReader reads a "deflate" body, which should be zlib wrapped
+// but is sent as raw deflate data by some servers.
+func newDeflateReader(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	header, err := br.Peek(2)
+	if err != nil {
+		return nil, err
+	}
+
+	// a zlib header is a multiple of 31 when read as a big-endian number
+	if header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0 {
+		return zlib.NewReader(br)
+	}
+	return flate.NewReader(br), nil
+}
@@ -0,0 +1,194 @@
+package scraper_test
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/andybalholm/brotli"
+)
+
+// encode compresses data with the given Content-Encoding.
+func encode(t *testing.T, encoding string, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	default:
+		t.Fatalf("unknown encoding %q", encoding)
+	}
+
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestBodyDecoding(t *testing.T) {
+	const page = "<html><body>héllo wörld</body></html>"
+	// a PNG signature followed by bytes which are not valid UTF-8
+	const png = "\x89PNG\r\n\x1a\n\x00\xff\xe9\x80caf\xe9"
+
+	tests := []struct {
+		name            string
+		contentEncoding string
+		contentType     string
+		noContentType   bool // stops the server from sniffing one
+		body            []byte
+		expectContent   string
+		expectErr       bool
+	}{
+		{
+			name:          "identity",
+			contentType:   "text/html; charset=utf-8",
+			body:          []byte(page),
+			expectContent: page,
+		},
+		{
+			name:            "gzip",
+			contentEncoding: "gzip",
+			body:            encode(t, "gzip", []byte(page)),
+			expectContent:   page,
+		},
+		{
+			name:            "zlib deflate",
+			contentEncoding: "deflate",
+			body:            encode(t, "deflate", []byte(page)),
+			expectContent:   page,
+		},
+		{
+			name:            "raw deflate",
+			contentEncoding: "deflate",
+			body:            encode(t, "raw-deflate", []byte(page)),
+			expectContent:   page,
+		},
+		{
+			name:            "brotli",
+			contentEncoding: "br",
+			body:            encode(t, "br", []byte(page)),
+			expectContent:   page,
+		},
+		{
+			name:            "stacked encodings",
+			contentEncoding: "deflate, gzip",
+			body:            encode(t, "gzip", encode(t, "deflate", []byte(page))),
+			expectContent:   page,
+		},
+		{
+			name:          "latin-1 converted to UTF-8",
+			contentType:   "text/html; charset=iso-8859-1",
+			body:          []byte("caf\xe9"),
+			expectContent: "café",
+		},
+		{
+			name:          "binary kept byte for byte",
+			contentType:   "image/png",
+			body:          []byte(png),
+			expectContent: png,
+		},
+		{
+			name:            "gzipped binary kept byte for byte",
+			contentEncoding: "gzip",
+			contentType:     "application/octet-stream",
+			body:            encode(t, "gzip", []byte(png)),
+			expectContent:   png,
+		},
+		{
+			name:          "binary without a Content-Type",
+			noContentType: true,
+			body:          []byte(png),
+			expectContent: png,
+		},
+		{
+			name:            "empty gzip body",
+			contentEncoding: "gzip",
+			expectContent:   "",
+		},
+		{
+			name:            "unsupported encoding",
+			contentEncoding: "zstd",
+			body:            []byte(page),
+			expectErr:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.contentEncoding != "" {
+					w.Header().Set("Content-Encoding", tt.contentEncoding)
+				}
+				if tt.contentType != "" {
+					w.Header().Set("Content-Type", tt.contentType)
+				}
+				if tt.noContentType {
+					w.Header()["Content-Type"] = nil
+				}
+				w.Write(tt.body)
+			}))
+			defer srv.Close()
+
+			result := scrapeOne(t, newTestScraper(t, 1), srv.URL)
+			if tt.expectErr {
+				if result.Error == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			if result.Content != tt.expectContent {
+				t.Errorf("expected content %q, got %q", tt.expectContent, result.Content)
+			}
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(strings.Repeat("é", 10)))
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	if err := s.SetMaxBodySize(5); err != nil {
+		t.Fatal(err)
+	}
+
+	result := scrapeOne(t, s, srv.URL)
+	if !result.Truncated {
+		t.Error("expected the body to be truncated")
+	}
+	// "é" is two bytes, so the cut falls back to the last whole character
+	if result.Content != "éé" || !utf8.ValidString(result.Content) {
+		t.Errorf("expected the content cut at a character boundary, got %q", result.Content)
+	}
+}
+
+func TestSetMaxBodySizeRejectsNonPositive(t *testing.T) {
+	s := newTestScraper(t, 1)
+	for _, n := range []int64{0, -1} {
+		if err := s.SetMaxBodySize(n); err == nil {
+			t.Errorf("expected an error for a max body size of %d", n)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package scraper
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ResponseCache stores the responses fetched by the default scrape function, by URL.
+type ResponseCache interface {
+	// Get returns the response cached for url, or nil if there is none.
+	Get(url string) (*CachedResponse, error)
+	// Put stores the response for url, replacing any earlier one.
+	Put(url string, resp *CachedResponse) error
+}
+
+// CachedResponse is a response kept in a ResponseCache, with its body already decoded.
+type CachedResponse struct {
+	URL        string
+	FinalURL   string
+	StatusCode int
+	Header     http.Header
+	Content    string
+	StoredAt   time.Time // when the response was received or last revalidated
+	// RequestHeader holds the request headers named by the Vary header of the response,
+	// as they were sent when it was fetched.
+	RequestHeader http.Header
+}
+
+var _ ResponseCache = (*DiskCache)(nil)
+
+// fresh reports whether the response may still be used without asking the server,
+// following the Cache-Control max-age and Expires headers.
+func (cr *CachedResponse) fresh(now time.Time) bool {
+	cc := parseCacheControl(cr.Header)
+	if _, ok := cc["no-cache"]; ok {
+		return false
+	}
+
+	var lifetime time.Duration
+	if maxAge, ok := cc["max-age"]; ok {
+		secs, err := strconv.Atoi(maxAge)
+		if err != nil {
+			return false
+		}
+		lifetime = time.Duration(secs) * time.Second
+	} else if expires := cr.Header.Get("Expires"); expires != "" {
+		exp, err := http.ParseTime(expires)
+		if err != nil {
+			// an invalid Expires means already expired
+			return false
+		}
+		date, err := http.ParseTime(cr.Header.Get("Date"))
+		if err != nil {
+			date = cr.StoredAt
+		}
+		lifetime = exp.Sub(date)
+	} else {
+		return false
+	}
+
+	if age, err := strconv.Atoi(cr.Header.Get("Age")); err == nil {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	return now.Sub(cr.StoredAt) < lifetime
+}
+
+// matches reports whether req sends the same values as the request the response was fetched with
+// in every header the response varies on.
+func (cr *CachedResponse) matches(req *http.Request) bool {
+	for _, name := range varyHeaders(cr.Header) {
+		if !slices.Equal(req.Header.Values(name), cr.RequestHeader.Values(name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// revalidate adds the conditional headers asking the server to answer 304 if the response is unchanged.
+// Reports whether the response has a validator to send.
+func (cr *CachedResponse) revalidate(req *http.Request) bool {
+	etag := cr.Header.Get("ETag")
+	lastModified := cr.Header.Get("Last-Modified")
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	if lastModified != "" {
+		req.Header.Set("If-Modified-Since", lastModified)
+	}
+	return etag != "" || lastModified != ""
+}
+
+// notModified updates the response with the headers of a 304 answer to a revalidation.
+func (cr *CachedResponse) notModified(resp *http.Response, now time.Time) {
+	header := cr.Header.Clone()
+	for key, values := range resp.Header {
+		header[key] = values
+	}
+	cr.Header = header
+	cr.StoredAt = now
+}
+
+// result returns the cached response as a Result.
+func (cr *CachedResponse) result() Result {
+	return Result{
+		URL:        cr.URL,
+		FinalURL:   cr.FinalURL,
+		Content:    cr.Content,
+		StatusCode: cr.StatusCode,
+		Header:     cr.Header,
+	}
+}
+
+// cacheable reports whether a response may be stored. Only 200 responses are kept, as those
+// are the only ones a revalidation can stand in for, and only if their body was read in full,
+// which the caller checks. Responses to requests sending
+// credentials or cookies, and those setting cookies, belong to one session and are not kept either.
+func cacheable(resp *http.Response) bool {
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if _, ok := parseCacheControl(resp.Header)["no-store"]; ok {
+		return false
+	}
+	if resp.Header.Get("Set-Cookie") != "" {
+		return false
+	}
+	if resp.Request.Header.Get("Authorization") != "" || resp.Request.Header.Get("Cookie") != "" {
+		return false
+	}
+	// a response varying on something the server does not name can never be matched
+	return !slices.Contains(varyHeaders(resp.Header), "*")
+}
+
+// requestVary returns the headers of the request resp answers which the response varies on.
+func requestVary(resp *http.Response) http.Header {
+	names := varyHeaders(resp.Header)
+	if len(names) == 0 {
+		return nil
+	}
+
+	header := make(http.Header, len(names))
+	for _, name := range names {
+		if values := resp.Request.Header.Values(name); len(values) > 0 {
+			header[name] = values
+		}
+	}
+	return header
+}
+
+// varyHeaders returns the header names listed by the Vary header, in canonical form.
+func varyHeaders(header http.Header) []string {
+	var names []string
+	for _, value := range header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				names = append(names, http.CanonicalHeaderKey(name))
+			}
+		}
+	}
+	return names
+}
+
+// parseCacheControl returns the directives of the Cache-Control header by lowercased name.
+// Directives without a value map to "".
+func parseCacheControl(header http.Header) map[string]string {
+	cc := make(map[string]string)
+	for _, value := range header.Values("Cache-Control") {
+		for _, directive := range strings.Split(value, ",") {
+			name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+			if name == "" {
+				continue
+			}
+			cc[strings.ToLower(name)] = strings.Trim(arg, `"`)
+		}
+	}
+	return cc
+}
+
+// DiskCache is a ResponseCache keeping one JSON file per URL in a directory.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache creates a cache in dir, creating the directory if needed.
+func NewDiskCache(dir string) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskCache{dir: dir}, nil
+}
+
+func (dc *DiskCache) Get(url string) (*CachedResponse, error) {
+	data, err := os.ReadFile(dc.path(url))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cr CachedResponse
+	if err := json.Unmarshal(data, &cr); err != nil {
+		// a damaged entry is as good as none, and is replaced on the next Put
+		return nil, nil
+	}
+	return &cr, nil
+}
+
+func (dc *DiskCache) Put(url string, resp *CachedResponse) error {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	path := dc.path(url)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	// write to a temporary file first, so readers never see half an entry
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// path returns the file of url, spread over subdirectories by the first byte of its hash.
+func (dc *DiskCache) path(url string) string {
+	sum := sha256.Sum256([]byte(url))
+	name := hex.EncodeToString(sum[:])
+	return filepath.Join(dc.dir, name[:2], name+".json")
+}
@@ -0,0 +1,302 @@
+package scraper_test
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// newCachedScraper creates a test scraper keeping responses in a DiskCache in a temporary directory.
+func newCachedScraper(t *testing.T) *scraper.RateLimitedScraper {
+	t.Helper()
+
+	cache, err := scraper.NewDiskCache(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newTestScraper(t, 1)
+	s.SetCache(cache)
+	return s
+}
+
+func TestCacheFresh(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Write([]byte("cached page"))
+	}))
+	defer srv.Close()
+
+	s := newCachedScraper(t)
+	first := scrapeOne(t, s, srv.URL)
+	second := scrapeOne(t, s, srv.URL)
+
+	if first.FromCache {
+		t.Error("expected the first result to be fetched")
+	}
+	if !second.FromCache || second.Content != "cached page" {
+		t.Errorf("expected the second result from the cache, got %q (from cache: %v)", second.Content, second.FromCache)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected one request, got %d", n)
+	}
+}
+
+func TestCacheRevalidate(t *testing.T) {
+	const lastModified = "Mon, 02 Jan 2006 15:04:05 GMT"
+
+	tests := []struct {
+		name      string
+		validator string
+		value     string
+		condition string
+	}{
+		{
+			name:      "etag",
+			validator: "ETag",
+			value:     `"v1"`,
+			condition: "If-None-Match",
+		},
+		{
+			name:      "last modified",
+			validator: "Last-Modified",
+			value:     lastModified,
+			condition: "If-Modified-Since",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.Header().Set(tt.validator, tt.value)
+				if r.Header.Get(tt.condition) == tt.value {
+					w.WriteHeader(http.StatusNotModified)
+					return
+				}
+				w.Write([]byte("original"))
+			}))
+			defer srv.Close()
+
+			s := newCachedScraper(t)
+			scrapeOne(t, s, srv.URL)
+			second := scrapeOne(t, s, srv.URL)
+
+			if !second.NotModified || second.FromCache {
+				t.Errorf("expected a revalidated result, got not modified: %v, from cache: %v", second.NotModified, second.FromCache)
+			}
+			if second.Content != "original" || second.StatusCode != http.StatusOK {
+				t.Errorf("expected the cached 200 response, got %d %q", second.StatusCode, second.Content)
+			}
+			if n := requests.Load(); n != 2 {
+				t.Errorf("expected two requests, got %d", n)
+			}
+		})
+	}
+}
+
+func TestCacheNotStored(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		header      http.Header
+		template    scraper.RequestTemplate
+		maxBodySize int64
+	}{
+		{
+			name:   "no-store",
+			status: http.StatusOK,
+			header: http.Header{"Cache-Control": {"no-store, max-age=60"}},
+		},
+		{
+			name:   "status other than 200",
+			status: http.StatusNotFound,
+			header: http.Header{"Cache-Control": {"max-age=60"}},
+		},
+		{
+			name:     "request other than a plain GET",
+			status:   http.StatusOK,
+			header:   http.Header{"Cache-Control": {"max-age=60"}},
+			template: scraper.RequestTemplate{Method: http.MethodPost, Body: []byte("q=1")},
+		},
+		{
+			name:        "body cut off",
+			status:      http.StatusOK,
+			header:      http.Header{"Cache-Control": {"max-age=60"}},
+			maxBodySize: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				for key, values := range tt.header {
+					w.Header()[key] = values
+				}
+				w.WriteHeader(tt.status)
+				w.Write([]byte("a page longer than the limit"))
+			}))
+			defer srv.Close()
+
+			s := newCachedScraper(t)
+			s.SetRequestTemplate(tt.template)
+			if tt.maxBodySize > 0 {
+				if err := s.SetMaxBodySize(tt.maxBodySize); err != nil {
+					t.Fatal(err)
+				}
+			}
+			scrapeOne(t, s, srv.URL)
+			if second := scrapeOne(t, s, srv.URL); second.FromCache {
+				t.Error("expected the response not to be cached")
+			}
+			if n := requests.Load(); n != 2 {
+				t.Errorf("expected two requests, got %d", n)
+			}
+		})
+	}
+}
+
+func TestCacheVary(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Cache-Control", "max-age=60")
+		w.Header().Set("Vary", "Accept-Encoding, accept-language")
+		w.Write([]byte("page in " + r.Header.Get("Accept-Language")))
+	}))
+	defer srv.Close()
+
+	s := newCachedScraper(t)
+	scrapeIn := func(lang string) scraper.Result {
+		s.SetRequestTemplate(scraper.RequestTemplate{Header: http.Header{"Accept-Language": {lang}}})
+		return scrapeOne(t, s, srv.URL)
+	}
+
+	scrapeIn("en")
+	if same := scrapeIn("en"); !same.FromCache {
+		t.Error("expected a request with the same varying headers to be served from the cache")
+	}
+	if other := scrapeIn("de"); other.FromCache || other.Content != "page in de" {
+		t.Errorf("expected a request with other varying headers to be fetched, got %q (from cache: %v)", other.Content, other.FromCache)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Errorf("expected two requests, got %d", n)
+	}
+}
+
+func TestCacheSkipsSessionResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		header   http.Header
+		template func(t *testing.T, srvURL string) scraper.RequestTemplate
+	}{
+		{
+			name:   "response varying on anything",
+			header: http.Header{"Vary": {"*"}},
+		},
+		{
+			name:   "response setting a cookie",
+			header: http.Header{"Set-Cookie": {"session=abc"}},
+		},
+		{
+			name: "request with credentials",
+			template: func(t *testing.T, srvURL string) scraper.RequestTemplate {
+				return scraper.RequestTemplate{BearerToken: "secret"}
+			},
+		},
+		{
+			name: "request with cookies",
+			template: func(t *testing.T, srvURL string) scraper.RequestTemplate {
+				jar, err := cookiejar.New(nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				u, _ := url.Parse(srvURL)
+				jar.SetCookies(u, []*http.Cookie{{Name: "session", Value: "abc"}})
+				return scraper.RequestTemplate{Jar: jar}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				w.Header().Set("Cache-Control", "max-age=60")
+				for key, values := range tt.header {
+					w.Header()[key] = values
+				}
+				w.Write([]byte("private page"))
+			}))
+			defer srv.Close()
+
+			s := newCachedScraper(t)
+			if tt.template != nil {
+				s.SetRequestTemplate(tt.template(t, srv.URL))
+			}
+			scrapeOne(t, s, srv.URL)
+			if second := scrapeOne(t, s, srv.URL); second.FromCache {
+				t.Error("expected the response not to be cached")
+			}
+			if n := requests.Load(); n != 2 {
+				t.Errorf("expected two requests, got %d", n)
+			}
+		})
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	cache, err := scraper.NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const url = "https://example.com/page"
+	if cr, err := cache.Get(url); err != nil || cr != nil {
+		t.Fatalf("expected a miss, got %+v and %v", cr, err)
+	}
+
+	stored := &scraper.CachedResponse{
+		URL:        url,
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Etag": {`"v1"`}},
+		Content:    "page",
+		StoredAt:   time.Now().Truncate(time.Second),
+	}
+	if err := cache.Put(url, stored); err != nil {
+		t.Fatal(err)
+	}
+	got, err := cache.Get(url)
+	if err != nil || got == nil {
+		t.Fatalf("expected a hit, got %+v and %v", got, err)
+	}
+	if got.Content != stored.Content || got.Header.Get("ETag") != `"v1"` || !got.StoredAt.Equal(stored.StoredAt) {
+		t.Errorf("expected %+v, got %+v", stored, got)
+	}
+
+	// a damaged entry is treated as missing
+	entries, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected one entry on disk, got %v and %v", entries, err)
+	}
+	if err := os.WriteFile(entries[0], []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if cr, err := cache.Get(url); err != nil || cr != nil {
+		t.Errorf("expected a damaged entry to be a miss, got %+v and %v", cr, err)
+	}
+}
@@ -0,0 +1,246 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// CrawlOptions scopes a crawl started with Crawl.
+type CrawlOptions struct {
+	// MaxDepth is the number of links followed from a seed URL. 0 fetches only the seeds.
+	MaxDepth int
+	// MaxPages caps the number of URLs fetched, including the seeds. 0 means no cap.
+	MaxPages int
+	// AllowedDomains lists the hosts links are followed to, including their subdomains.
+	// Defaults to the hosts of the seed URLs, without subdomains.
+	AllowedDomains []string
+	// Include, if set, only follows links whose path matches one of the patterns.
+	Include []*regexp.Regexp
+	// Exclude never follows links whose path matches one of the patterns.
+	Exclude []*regexp.Regexp
+}
+
+// Crawl scrapes the seed URLs and then follows the links found in HTML pages,
+// within the scope set by opts. Every URL is fetched at most once. Crawling uses
+// the same worker pool, rate limiters and retry policy as ScrapeURLs.
+// The results channel is closed once there is nothing left to fetch.
+func (s *RateLimitedScraper) Crawl(
+	ctx context.Context,
+	seeds []string,
+	opts CrawlOptions) (<-chan Result, error) {
+	return s.crawl(ctx, seeds, opts, nil)
+}
+
+// crawl runs a crawl, recording its frontier and results in state if it is part of a job.
+// URLs queued by an earlier run of the job are not scheduled again, and those not completed
+// are fetched before anything else.
+func (s *RateLimitedScraper) crawl(
+	ctx context.Context,
+	seeds []string,
+	opts CrawlOptions,
+	state *jobState) (<-chan Result, error) {
+	if s.limiter == nil && s.hostLimits == nil {
+		return nil, fmt.Errorf("rate limiter not set")
+	}
+
+	c := &crawler{
+		opts: opts,
+		seen: make(map[string]bool),
+	}
+
+	var queue []job
+	if state != nil {
+		for _, q := range state.queued {
+			c.seen[q.URL] = true
+			c.scheduled++
+			if !state.done[q.URL] {
+				queue = append(queue, job{url: q.URL, depth: q.Depth})
+			}
+		}
+	}
+
+	for _, seed := range seeds {
+		u, err := NormalizeURL(seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid seed URL: %w", err)
+		}
+		if len(opts.AllowedDomains) == 0 {
+			parsed, _ := url.Parse(u)
+			c.seedHosts = append(c.seedHosts, parsed.Hostname())
+		}
+		if c.schedule(u) {
+			if !state.queue(QueuedURL{URL: u}) {
+				return nil, state.job.Err()
+			}
+			queue = append(queue, job{url: u})
+		}
+	}
+
+	s.stats.begin()
+	s.stats.queue(len(queue))
+	ctx, cancel := context.WithCancel(ctx)
+	jobs := make(chan job)
+	found := make(chan Result)
+	results := make(chan Result, s.maxWorkers)
+	s.startWorkers(ctx, jobs, found)
+
+	go func() {
+		defer close(results)
+		defer func() {
+			// the run ends once the workers are done, which is before results is closed
+			for range found {
+			}
+		}()
+		defer close(jobs)
+		defer cancel()
+		defer func() {
+			// URLs left in the queue when the crawl stops early are never scraped
+			s.stats.drop(len(queue))
+		}()
+
+		pending := 0
+		for len(queue) > 0 || pending > 0 {
+			var next chan<- job
+			var head job
+			if len(queue) > 0 {
+				next, head = jobs, queue[0]
+			}
+
+			select {
+			case next <- head:
+				queue = queue[1:]
+				pending++
+			case result, ok := <-found:
+				if !ok {
+					// every worker gave up, because the context is done
+					return
+				}
+				pending--
+
+				// the links are stored before the page, so a resumed job never loses them
+				for _, j := range c.follow(result) {
+					if !state.queue(QueuedURL{URL: j.url, Depth: j.depth}) {
+						return
+					}
+					queue = append(queue, j)
+					s.stats.queue(1)
+				}
+				if !state.complete(ctx, result) {
+					return
+				}
+
+				select {
+				case results <- result:
+				case <-ctx.Done():
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return results, nil
+}
+
+// crawler keeps track of the URLs scheduled during a crawl. It is only used by one goroutine.
+type crawler struct {
+	opts      CrawlOptions
+	seedHosts []string
+	seen      map[string]bool
+	scheduled int
+}
+
+// follow returns the jobs for the links of result which are in scope and not seen yet.
+func (c *crawler) follow(result Result) []job {
+	pageURL := result.FinalURL
+	if pageURL == "" {
+		pageURL = result.URL
+	}
+	// a redirect target is the same page as the URL which was scheduled
+	if u, err := NormalizeURL(pageURL); err == nil {
+		c.seen[u] = true
+	}
+
+	if result.Error != nil || result.Depth >= c.opts.MaxDepth || !isHTML(result) {
+		return nil
+	}
+
+	var jobs []job
+	for _, link := range ExtractLinks(pageURL, result.Content) {
+		if c.inScope(link) && c.schedule(link) {
+			jobs = append(jobs, job{url: link, depth: result.Depth + 1})
+		}
+	}
+	return jobs
+}
+
+// schedule marks a normalised URL as seen, reporting false if it was seen before
+// or the page budget is used up.
+func (c *crawler) schedule(u string) bool {
+	if c.seen[u] || (c.opts.MaxPages > 0 && c.scheduled >= c.opts.MaxPages) {
+		return false
+	}
+	c.seen[u] = true
+	c.scheduled++
+	return true
+}
+
+// inScope reports whether a discovered link may be followed.
+func (c *crawler) inScope(link string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	if !c.allowedHost(u.Hostname()) {
+		return false
+	}
+
+	for _, re := range c.opts.Exclude {
+		if re.MatchString(u.Path) {
+			return false
+		}
+	}
+	if len(c.opts.Include) == 0 {
+		return true
+	}
+	for _, re := range c.opts.Include {
+		if re.MatchString(u.Path) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *crawler) allowedHost(host string) bool {
+	if len(c.opts.AllowedDomains) == 0 {
+		for _, seedHost := range c.seedHosts {
+			if host == seedHost {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, domain := range c.opts.AllowedDomains {
+		domain = strings.ToLower(domain)
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHTML reports whether result holds an HTML page worth parsing for links.
+func isHTML(result Result) bool {
+	contentType := result.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType([]byte(result.Content))
+	}
+	return strings.Contains(contentType, "html")
+}
@@ -0,0 +1,173 @@
+package scraper_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// newTestSite serves HTML pages whose bodies link to the given paths.
+func newTestSite(t *testing.T, pages map[string][]string) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		links, ok := pages[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, "<html><body>")
+		for _, link := range links {
+			fmt.Fprintf(w, `<a href="%s">%s</a>`, link, link)
+		}
+		fmt.Fprint(w, "</body></html>")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// crawlPaths crawls from the root of srv and returns the paths fetched with their depths.
+func crawlPaths(t *testing.T, srv *httptest.Server, opts scraper.CrawlOptions) map[string]int {
+	t.Helper()
+
+	results, err := newTestScraper(t, 2).Crawl(context.Background(), []string{srv.URL + "/"}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := make(map[string]int)
+	for _, result := range collect(t, results) {
+		if result.Error != nil {
+			t.Errorf("%s: unexpected error %v", result.URL, result.Error)
+		}
+		paths[strings.TrimPrefix(result.URL, srv.URL)] = result.Depth
+	}
+	return paths
+}
+
+func TestCrawl(t *testing.T) {
+	pages := map[string][]string{
+		"/":          {"/a", "/b", "/private/x", "https://elsewhere.example/"},
+		"/a":         {"/", "/a/deep"},
+		"/b":         {"/a#fragment"},
+		"/a/deep":    {"/a/deeper"},
+		"/a/deeper":  nil,
+		"/private/x": nil,
+	}
+
+	tests := []struct {
+		name        string
+		opts        scraper.CrawlOptions
+		expectPaths map[string]int
+	}{
+		{
+			name:        "seeds only",
+			opts:        scraper.CrawlOptions{},
+			expectPaths: map[string]int{"/": 0},
+		},
+		{
+			name: "follows links once each within the seed host",
+			opts: scraper.CrawlOptions{MaxDepth: 2},
+			expectPaths: map[string]int{
+				"/": 0, "/a": 1, "/b": 1, "/private/x": 1, "/a/deep": 2,
+			},
+		},
+		{
+			name: "exclude patterns",
+			opts: scraper.CrawlOptions{MaxDepth: 1, Exclude: []*regexp.Regexp{regexp.MustCompile(`^/private/`)}},
+			expectPaths: map[string]int{
+				"/": 0, "/a": 1, "/b": 1,
+			},
+		},
+		{
+			name:        "include patterns",
+			opts:        scraper.CrawlOptions{MaxDepth: 3, Include: []*regexp.Regexp{regexp.MustCompile(`^/a`)}},
+			expectPaths: map[string]int{"/": 0, "/a": 1, "/a/deep": 2, "/a/deeper": 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newTestSite(t, pages)
+
+			paths := crawlPaths(t, srv, tt.opts)
+			if len(paths) != len(tt.expectPaths) {
+				t.Errorf("expected %v, got %v", tt.expectPaths, paths)
+			}
+			for path, depth := range tt.expectPaths {
+				if got, ok := paths[path]; !ok || got != depth {
+					t.Errorf("expected %s at depth %d, got %v (found: %v)", path, depth, got, ok)
+				}
+			}
+		})
+	}
+}
+
+func TestCrawlMaxPages(t *testing.T) {
+	srv := newTestSite(t, map[string][]string{
+		"/":  {"/1", "/2", "/3", "/4"},
+		"/1": nil, "/2": nil, "/3": nil, "/4": nil,
+	})
+
+	paths := crawlPaths(t, srv, scraper.CrawlOptions{MaxDepth: 1, MaxPages: 3})
+	if len(paths) != 3 {
+		t.Errorf("expected 3 pages including the seed, got %v", paths)
+	}
+}
+
+func TestCrawlAllowedDomains(t *testing.T) {
+	other := newTestSite(t, map[string][]string{"/": nil})
+	// the seed is fetched from 127.0.0.1 and links to the other site as localhost
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1) + "/"
+	srv := newTestSite(t, map[string][]string{"/": {otherURL}})
+
+	tests := []struct {
+		name           string
+		allowedDomains []string
+		expectURLs     []string
+	}{
+		{
+			name:       "defaults to the seed hosts",
+			expectURLs: []string{srv.URL + "/"},
+		},
+		{
+			name:           "allowed domains",
+			allowedDomains: []string{"localhost"},
+			expectURLs:     []string{srv.URL + "/", otherURL}, // sorted: 127.0.0.1 before localhost
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := scraper.CrawlOptions{MaxDepth: 1, AllowedDomains: tt.allowedDomains}
+			results, err := newTestScraper(t, 1).Crawl(context.Background(), []string{srv.URL + "/"}, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var urls []string
+			for _, result := range collect(t, results) {
+				urls = append(urls, result.URL)
+			}
+			slices.Sort(urls)
+			if !slices.Equal(urls, tt.expectURLs) {
+				t.Errorf("expected %v, got %v", tt.expectURLs, urls)
+			}
+		})
+	}
+}
+
+func TestCrawlInvalidSeed(t *testing.T) {
+	if _, err := newTestScraper(t, 1).Crawl(context.Background(), []string{"ftp://example.com"}, scraper.CrawlOptions{}); err == nil {
+		t.Error("expected an error for an unsupported seed URL")
+	}
+}
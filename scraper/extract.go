@@ -0,0 +1,394 @@
+package scraper
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/andybalholm/cascadia"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// Extractor pulls structured data out of a scraped HTML page and stores it in the result.
+// Extractors run in the order they were set, after the page is fetched and any retries are done.
+type Extractor interface {
+	Extract(doc *html.Node, result *Result) error
+}
+
+// ExtractorFunc adapts a function to the Extractor interface.
+type ExtractorFunc func(doc *html.Node, result *Result) error
+
+func (f ExtractorFunc) Extract(doc *html.Node, result *Result) error {
+	return f(doc, result)
+}
+
+var (
+	_ Extractor = (*SelectorExtractor)(nil)
+	_ Extractor = MainTextExtractor{}
+	_ Extractor = MetaExtractor{}
+)
+
+// PageMeta holds the metadata found in a page's <head>.
+type PageMeta struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	Canonical   string `json:"canonical,omitempty"`
+	// Meta holds the content of every <meta name=...> tag, by lowercased name.
+	Meta map[string]string `json:"meta,omitempty"`
+	// OpenGraph holds the og:* properties without their prefix, e.g. "title" and "image".
+	OpenGraph map[string]string `json:"open_graph,omitempty"`
+}
+
+// extract parses the content of result and runs the extractors on it.
+// Results which failed or are not HTML are left alone. Errors go to Result.ExtractError,
+// as the page itself was fetched; an extractor failing does not stop the next ones.
+func (s *RateLimitedScraper) extract(result *Result) {
+	if len(s.extractors) == 0 || result.Error != nil || !isHTML(*result) {
+		return
+	}
+
+	doc, err := html.Parse(strings.NewReader(result.Content))
+	if err != nil {
+		result.ExtractError = fmt.Errorf("parsing HTML: %w", err)
+		return
+	}
+
+	var errs []error
+	for _, ex := range s.extractors {
+		if err := ex.Extract(doc, result); err != nil {
+			errs = append(errs, fmt.Errorf("extracting: %w", err))
+		}
+	}
+	result.ExtractError = errors.Join(errs...)
+}
+
+// SelectorExtractor fills Result.Fields with the text of the first element matching
+// a CSS selector per field. A selector ending in @name takes that attribute instead,
+// e.g. "img.product@src"; an @ inside an attribute selector or a quoted string, as in
+// a[href^="mailto:sales@"], is part of the selector. Fields without a match are left out.
+type SelectorExtractor struct {
+	fields map[string]fieldSelector
+}
+
+type fieldSelector struct {
+	sel  cascadia.Sel
+	attr string
+}
+
+// NewSelectorExtractor compiles the selector of every field.
+func NewSelectorExtractor(fields map[string]string) (*SelectorExtractor, error) {
+	se := &SelectorExtractor{fields: make(map[string]fieldSelector, len(fields))}
+	for name, selector := range fields {
+		var attr string
+		// the attribute comes last, after any bracket or quote of the selector
+		if i := strings.LastIndex(selector, "@"); i > strings.LastIndexAny(selector, `])"'`) {
+			selector, attr = selector[:i], selector[i+1:]
+		}
+
+		sel, err := cascadia.Parse(selector)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", name, err)
+		}
+		se.fields[name] = fieldSelector{sel: sel, attr: attr}
+	}
+	return se, nil
+}
+
+func (se *SelectorExtractor) Extract(doc *html.Node, result *Result) error {
+	if result.Fields == nil {
+		result.Fields = make(map[string]string, len(se.fields))
+	}
+
+	for name, field := range se.fields {
+		n := cascadia.Query(doc, field.sel)
+		if n == nil {
+			continue
+		}
+
+		if field.attr == "" {
+			result.Fields[name] = textOf(n)
+		} else if val, ok := attrOf(n, field.attr); ok {
+			result.Fields[name] = strings.TrimSpace(val)
+		}
+	}
+	return nil
+}
+
+// MainTextExtractor fills Result.MainText with the main text of the page, leaving out
+// navigation, sidebars, comments and other boilerplate. Like readability, it scores every
+// block by the amount of prose it contains, penalising link-heavy blocks and using class
+// and id names as hints, and takes the paragraphs of the best block.
+type MainTextExtractor struct{}
+
+func (MainTextExtractor) Extract(doc *html.Node, result *Result) error {
+	body := findElement(doc, atom.Body)
+	if body == nil {
+		body = doc
+	}
+
+	scores := make(map[*html.Node]float64)
+	var candidates []*html.Node // the nodes scored, in document order
+	add := func(n *html.Node, score float64) {
+		if _, ok := scores[n]; !ok {
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+	walk(body, func(n *html.Node) bool {
+		if skipped(n) {
+			return false
+		}
+		if n.Type != html.ElementNode || (n.DataAtom != atom.P && n.DataAtom != atom.Pre && n.DataAtom != atom.Td) {
+			return true
+		}
+
+		text := textOf(n)
+		if len(text) < 25 {
+			return false
+		}
+
+		// more text and more clauses make a block look like prose
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		if parent := n.Parent; parent != nil {
+			add(parent, score)
+			if grandparent := parent.Parent; grandparent != nil {
+				add(grandparent, score/2)
+			}
+		}
+		return false
+	})
+
+	// ties go to the block coming first
+	var best *html.Node
+	var bestScore float64
+	for _, n := range candidates {
+		score := scores[n]*(1-linkDensity(n)) + classWeight(n)
+		if best == nil || score > bestScore {
+			best, bestScore = n, score
+		}
+	}
+	if best == nil {
+		result.MainText = textOf(body)
+		return nil
+	}
+
+	var paragraphs []string
+	walk(best, func(n *html.Node) bool {
+		if skipped(n) || (n.Type == html.ElementNode && classWeight(n) < 0) {
+			return false
+		}
+		if n.Type == html.ElementNode && isBlock(n.DataAtom) && !hasBlockChild(n) {
+			if text := textOf(n); text != "" {
+				paragraphs = append(paragraphs, text)
+			}
+			return false
+		}
+		return true
+	})
+	result.MainText = strings.Join(paragraphs, "\n\n")
+	return nil
+}
+
+// MetaExtractor fills Result.Meta with the page title, description, canonical URL,
+// <meta> tags and OpenGraph properties.
+type MetaExtractor struct{}
+
+func (MetaExtractor) Extract(doc *html.Node, result *Result) error {
+	meta := &PageMeta{
+		Meta:      make(map[string]string),
+		OpenGraph: make(map[string]string),
+	}
+
+	walk(doc, func(n *html.Node) bool {
+		if n.Type != html.ElementNode {
+			return true
+		}
+
+		switch n.DataAtom {
+		case atom.Title:
+			if meta.Title == "" {
+				meta.Title = textOf(n)
+			}
+		case atom.Link:
+			rel, _ := attrOf(n, "rel")
+			if href, ok := attrOf(n, "href"); ok && strings.EqualFold(rel, "canonical") {
+				meta.Canonical = strings.TrimSpace(href)
+			}
+		case atom.Meta:
+			content, ok := attrOf(n, "content")
+			if !ok {
+				break
+			}
+			content = strings.TrimSpace(content)
+			if property, ok := attrOf(n, "property"); ok && strings.HasPrefix(property, "og:") {
+				meta.OpenGraph[strings.TrimPrefix(property, "og:")] = content
+			}
+			if name, ok := attrOf(n, "name"); ok {
+				meta.Meta[strings.ToLower(name)] = content
+			}
+		}
+		return true
+	})
+
+	meta.Description = meta.Meta["description"]
+	if meta.Description == "" {
+		meta.Description = meta.OpenGraph["description"]
+	}
+	if meta.Title == "" {
+		meta.Title = meta.OpenGraph["title"]
+	}
+
+	result.Meta = meta
+	return nil
+}
+
+// walk calls fn for n and its descendants in document order, skipping the children
+// of nodes for which fn returns false.
+func walk(n *html.Node, fn func(n *html.Node) bool) {
+	if !fn(n) {
+		return
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		walk(c, fn)
+	}
+}
+
+func findElement(n *html.Node, a atom.Atom) *html.Node {
+	var found *html.Node
+	walk(n, func(n *html.Node) bool {
+		if found != nil {
+			return false
+		}
+		if n.Type == html.ElementNode && n.DataAtom == a {
+			found = n
+			return false
+		}
+		return true
+	})
+	return found
+}
+
+// textOf returns the visible text of n with whitespace collapsed.
+func textOf(n *html.Node) string {
+	var b strings.Builder
+	var write func(n *html.Node)
+	write = func(n *html.Node) {
+		if skipped(n) {
+			return
+		}
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+			return
+		}
+
+		// block elements and line breaks separate words, inline elements do not
+		separate := n.Type == html.ElementNode && (isBlock(n.DataAtom) || n.DataAtom == atom.Br)
+		if separate {
+			b.WriteByte(' ')
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			write(c)
+		}
+		if separate {
+			b.WriteByte(' ')
+		}
+	}
+	write(n)
+
+	return strings.Join(strings.FieldsFunc(b.String(), unicode.IsSpace), " ")
+}
+
+func attrOf(n *html.Node, name string) (string, bool) {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, name) {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// skipped reports whether n never holds readable text.
+func skipped(n *html.Node) bool {
+	if n.Type == html.CommentNode {
+		return true
+	}
+	if n.Type != html.ElementNode {
+		return false
+	}
+	switch n.DataAtom {
+	case atom.Script, atom.Style, atom.Noscript, atom.Template, atom.Svg, atom.Iframe, atom.Head:
+		return true
+	}
+	return false
+}
+
+// linkDensity returns the share of the text of n which is inside links.
+func linkDensity(n *html.Node) float64 {
+	total := len(textOf(n))
+	if total == 0 {
+		return 0
+	}
+
+	linked := 0
+	walk(n, func(c *html.Node) bool {
+		if c.Type == html.ElementNode && c.DataAtom == atom.A {
+			linked += len(textOf(c))
+			return false
+		}
+		return true
+	})
+	return float64(linked) / float64(total)
+}
+
+var (
+	positiveHints = []string{"article", "body", "content", "entry", "main", "page", "post", "story", "text"}
+	negativeHints = []string{"ad-", "banner", "comment", "footer", "header", "menu", "nav", "related", "share", "sidebar", "social", "sponsor", "widget"}
+)
+
+// classWeight scores the class and id of n by how likely they are to name the main content.
+func classWeight(n *html.Node) float64 {
+	class, _ := attrOf(n, "class")
+	id, _ := attrOf(n, "id")
+	names := strings.ToLower(class + " " + id)
+
+	weight := 0.0
+	for _, hint := range negativeHints {
+		if strings.Contains(names, hint) {
+			weight -= 25
+		}
+	}
+	for _, hint := range positiveHints {
+		if strings.Contains(names, hint) {
+			weight += 25
+		}
+	}
+	switch n.DataAtom {
+	case atom.Article, atom.Main:
+		weight += 25
+	case atom.Nav, atom.Aside, atom.Footer, atom.Header, atom.Form:
+		weight -= 25
+	}
+	return weight
+}
+
+var blockAtoms = map[atom.Atom]bool{
+	atom.P: true, atom.Pre: true, atom.Blockquote: true, atom.Li: true, atom.Td: true,
+	atom.H1: true, atom.H2: true, atom.H3: true, atom.H4: true, atom.H5: true, atom.H6: true,
+	atom.Div: true, atom.Section: true, atom.Article: true, atom.Ul: true, atom.Ol: true, atom.Table: true,
+}
+
+func isBlock(a atom.Atom) bool {
+	return blockAtoms[a]
+}
+
+func hasBlockChild(n *html.Node) bool {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && isBlock(c.DataAtom) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,199 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+	"golang.org/x/net/html"
+)
+
+const articlePage = `<html>
+<head>
+	<title>Go Scheduling</title>
+	<meta name="Description" content=" How goroutines are scheduled. ">
+	<meta property="og:image" content="https://example.com/cover.png">
+	<link rel="canonical" href="https://example.com/articles/scheduling">
+</head>
+<body>
+	<nav class="menu"><a href="/">Home</a> <a href="/articles">Articles</a> <a href="/about">About us and the team</a></nav>
+	<div id="content" class="article">
+		<h1 class="headline">Go Scheduling</h1>
+		<p>The Go runtime multiplexes goroutines onto threads, parking them while they block.</p>
+		<p>Each processor keeps a local run queue, and idle ones steal work from the others.</p>
+		<img class="cover" src="/cover.png">
+		<div class="comments"><p>Great article, thanks for writing it up in so much detail!</p></div>
+	</div>
+	<footer><p>Copyright 2024, Example Inc., all rights reserved, and so on.</p></footer>
+</body>
+</html>`
+
+// extract runs ex on page and returns the result it filled in.
+func extract(t *testing.T, ex scraper.Extractor, page string) scraper.Result {
+	t.Helper()
+
+	doc, err := html.Parse(strings.NewReader(page))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var result scraper.Result
+	if err := ex.Extract(doc, &result); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return result
+}
+
+func TestSelectorExtractor(t *testing.T) {
+	ex, err := scraper.NewSelectorExtractor(map[string]string{
+		"headline": "h1.headline",
+		"cover":    "img.cover@src",
+		"first":    "#content p",
+		"missing":  ".does-not-exist",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := extract(t, ex, articlePage).Fields
+	expect := map[string]string{
+		"headline": "Go Scheduling",
+		"cover":    "/cover.png",
+		"first":    "The Go runtime multiplexes goroutines onto threads, parking them while they block.",
+	}
+	if len(fields) != len(expect) {
+		t.Errorf("expected fields %v, got %v", expect, fields)
+	}
+	for name, value := range expect {
+		if fields[name] != value {
+			t.Errorf("field %s: expected %q, got %q", name, value, fields[name])
+		}
+	}
+}
+
+func TestSelectorExtractorAtInSelector(t *testing.T) {
+	ex, err := scraper.NewSelectorExtractor(map[string]string{
+		"email":   `a[href^="mailto:sales@"]`,
+		"address": `a[href^="mailto:sales@"]@href`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := extract(t, ex, `<p>Write to <a href="mailto:sales@example.com">our sales team</a>.</p>`).Fields
+	if fields["email"] != "our sales team" || fields["address"] != "mailto:sales@example.com" {
+		t.Errorf("expected the @ inside the brackets to be part of the selector, got %v", fields)
+	}
+}
+
+func TestSelectorExtractorInvalidSelector(t *testing.T) {
+	if _, err := scraper.NewSelectorExtractor(map[string]string{"bad": "div["}); err == nil {
+		t.Error("expected an error for an invalid selector")
+	}
+}
+
+func TestMainTextExtractor(t *testing.T) {
+	text := extract(t, scraper.MainTextExtractor{}, articlePage).MainText
+
+	for _, want := range []string{"multiplexes goroutines", "local run queue"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected main text to contain %q, got %q", want, text)
+		}
+	}
+	for _, unwanted := range []string{"About us", "Great article", "Copyright"} {
+		if strings.Contains(text, unwanted) {
+			t.Errorf("expected main text to leave out %q, got %q", unwanted, text)
+		}
+	}
+}
+
+func TestMainTextExtractorTie(t *testing.T) {
+	const page = `<html><body>
+		<div><p>Alpha paragraph, which is long enough to count as prose.</p></div>
+		<div><p>Omega paragraph, which is long enough to count as prose.</p></div>
+	</body></html>`
+
+	// blocks scoring the same are picked in document order, every time
+	for range 20 {
+		if text := extract(t, scraper.MainTextExtractor{}, page).MainText; !strings.HasPrefix(text, "Alpha") {
+			t.Fatalf("expected the first of the blocks scoring the same, got %q", text)
+		}
+	}
+}
+
+func TestMetaExtractor(t *testing.T) {
+	meta := extract(t, scraper.MetaExtractor{}, articlePage).Meta
+	if meta == nil {
+		t.Fatal("expected meta to be set")
+	}
+
+	if meta.Title != "Go Scheduling" {
+		t.Errorf("expected title %q, got %q", "Go Scheduling", meta.Title)
+	}
+	if meta.Description != "How goroutines are scheduled." {
+		t.Errorf("expected the trimmed description, got %q", meta.Description)
+	}
+	if meta.Canonical != "https://example.com/articles/scheduling" {
+		t.Errorf("expected the canonical URL, got %q", meta.Canonical)
+	}
+	if meta.OpenGraph["image"] != "https://example.com/cover.png" {
+		t.Errorf("expected the og:image property, got %v", meta.OpenGraph)
+	}
+}
+
+func TestMetaExtractorFallsBackToOpenGraph(t *testing.T) {
+	page := `<head><meta property="og:title" content="OG Title"><meta property="og:description" content="OG description"></head>`
+
+	meta := extract(t, scraper.MetaExtractor{}, page).Meta
+	if meta.Title != "OG Title" || meta.Description != "OG description" {
+		t.Errorf("expected the OpenGraph title and description, got %q and %q", meta.Title, meta.Description)
+	}
+}
+
+func TestScrapeRunsExtractors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/data.json" {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"title": "not a page"}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(articlePage))
+	}))
+	defer srv.Close()
+
+	failing := scraper.ExtractorFunc(func(doc *html.Node, result *scraper.Result) error {
+		if strings.HasSuffix(result.URL, "/broken") {
+			return errors.New("no price found")
+		}
+		return nil
+	})
+
+	s := newTestScraper(t, 1)
+	s.SetExtractors(failing, scraper.MetaExtractor{})
+
+	results, err := s.ScrapeURLs(context.Background(), []string{srv.URL + "/article", srv.URL + "/data.json", srv.URL + "/broken"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byURL(collect(t, results))
+
+	if article := got[srv.URL+"/article"]; article.Meta == nil || article.Meta.Title != "Go Scheduling" {
+		t.Errorf("expected the HTML page to be extracted, got %+v", article.Meta)
+	}
+	if data := got[srv.URL+"/data.json"]; data.Meta != nil || data.Error != nil {
+		t.Errorf("expected pages other than HTML to be left alone, got %+v and %v", data.Meta, data.Error)
+	}
+	// the page was fetched, so a failed extractor is reported apart, and the others still run
+	broken := got[srv.URL+"/broken"]
+	if broken.Error != nil || broken.ExtractError == nil {
+		t.Errorf("expected the extractor error in ExtractError only, got %v and %v", broken.Error, broken.ExtractError)
+	}
+	if broken.Meta == nil || broken.Meta.Title != "Go Scheduling" {
+		t.Errorf("expected the extractors after the failed one to run, got %+v", broken.Meta)
+	}
+}
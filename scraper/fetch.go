@@ -0,0 +1,264 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Fetcher fetches a single URL. Rate limiting, robots.txt, retries and extractors
+// are handled by the scraper around it, whichever fetcher runs.
+type Fetcher interface {
+	Fetch(ctx context.Context, url string) Result
+}
+
+// OfflineFetcher is implemented by fetchers which do not visit the sites of the URLs they fetch,
+// such as FileFetcher. robots.txt is not checked for the URLs they fetch.
+type OfflineFetcher interface {
+	Fetcher
+	Offline() bool
+}
+
+// limitedFetcher is implemented by fetchers reading bodies themselves, which are given
+// the maximum body size set on the scraper.
+type limitedFetcher interface {
+	fetchLimited(ctx context.Context, url string, maxBodySize int64) Result
+}
+
+// Fetch makes a ScrapeFunc usable as a Fetcher.
+func (f ScrapeFunc) Fetch(ctx context.Context, url string) Result {
+	return f(ctx, url)
+}
+
+var (
+	_ Fetcher = ScrapeFunc(nil)
+	_ Fetcher = FileFetcher{}
+	_ Fetcher = (*RenderFetcher)(nil)
+
+	_ OfflineFetcher = FileFetcher{}
+	_ limitedFetcher = (*RenderFetcher)(nil)
+)
+
+// Route sends the URLs it matches to a fetcher other than the default one.
+// A route with both Hosts and Pattern set matches URLs satisfying both.
+type Route struct {
+	// Hosts lists the hosts routed, including their subdomains.
+	Hosts []string
+	// Pattern, if set, routes URLs matching it.
+	Pattern *regexp.Regexp
+	// Fetcher fetches the routed URLs.
+	Fetcher Fetcher
+}
+
+// matches reports whether rawURL is routed by r.
+func (r Route) matches(rawURL string) bool {
+	if len(r.Hosts) == 0 && r.Pattern == nil {
+		return false
+	}
+
+	if len(r.Hosts) > 0 {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return false
+		}
+		host := strings.ToLower(u.Hostname())
+
+		found := false
+		for _, h := range r.Hosts {
+			h = strings.ToLower(h)
+			if host == h || strings.HasSuffix(host, "."+h) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return r.Pattern == nil || r.Pattern.MatchString(rawURL)
+}
+
+// fetcherFor returns the fetcher of the first route matching url, or the default fetcher.
+func (s *RateLimitedScraper) fetcherFor(url string) Fetcher {
+	for _, route := range s.routes {
+		if route.matches(url) {
+			return route.Fetcher
+		}
+	}
+	return s.fetcher
+}
+
+// fetch fetches url with its fetcher.
+func (s *RateLimitedScraper) fetch(ctx context.Context, url string) Result {
+	f := s.fetcherFor(url)
+	if lf, ok := f.(limitedFetcher); ok {
+		return lf.fetchLimited(ctx, url, s.maxBodySize)
+	}
+	return f.Fetch(ctx, url)
+}
+
+// offline reports whether url is fetched without visiting its site, so robots.txt does not apply.
+func (s *RateLimitedScraper) offline(url string) bool {
+	of, ok := s.fetcherFor(url).(OfflineFetcher)
+	return ok && of.Offline()
+}
+
+// FileFetcher reads pages from local files. file:// URLs are read from their path,
+// and http(s) URLs from a mirror under Dir laid out as <Dir>/<host>/<path>,
+// with index.html standing in for paths ending in a slash or naming a directory.
+// The host directory is the lowercased host name, with "_<port>" appended for URLs with a port.
+// This is the layout written by DirSink.
+type FileFetcher struct {
+	Dir string
+}
+
+// Offline reports true, as files are read from disk.
+func (ff FileFetcher) Offline() bool {
+	return true
+}
+
+func (ff FileFetcher) Fetch(ctx context.Context, rawURL string) Result {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Result{URL: rawURL, Error: err}
+	}
+
+	var name string
+	switch {
+	case u.Scheme == "file":
+		name = filepath.FromSlash(u.Path)
+	case ff.Dir != "":
+		name, err = mirrorFile(ff.Dir, u, u.Path)
+		if err != nil {
+			return Result{URL: rawURL, Error: err}
+		}
+		if info, err := os.Stat(name); err == nil && info.IsDir() {
+			name = filepath.Join(name, "index.html")
+		}
+	default:
+		return Result{URL: rawURL, Error: fmt.Errorf("no local file for %s", rawURL)}
+	}
+
+	content, err := os.ReadFile(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		return Result{URL: rawURL, FinalURL: rawURL, StatusCode: http.StatusNotFound, Header: http.Header{}}
+	}
+	if err != nil {
+		return Result{URL: rawURL, Error: err}
+	}
+
+	header := http.Header{}
+	if contentType := mime.TypeByExtension(filepath.Ext(name)); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	return Result{
+		URL:        rawURL,
+		FinalURL:   rawURL,
+		Content:    string(content),
+		StatusCode: http.StatusOK,
+		Header:     header,
+	}
+}
+
+// mirrorFile returns the file of the page at path p of u's host in the mirror under dir, laid out as
+// <dir>/<host>/<path> with index.html standing in for paths ending in a slash. The host directory
+// is the lowercased host name, followed by an underscore and the port if the URL has one.
+func mirrorFile(dir string, u *url.URL, p string) (string, error) {
+	host := strings.ToLower(u.Hostname())
+	if host == "" || host == "." || host == ".." || strings.ContainsAny(host, `/\`) {
+		return "", fmt.Errorf("no mirror directory for host %q", u.Host)
+	}
+	// IPv6 addresses are written without colons, which some file systems reject
+	host = strings.ReplaceAll(host, ":", "_")
+	if port := u.Port(); port != "" {
+		host += "_" + port
+	}
+
+	if p == "" || strings.HasSuffix(p, "/") {
+		p += "index.html"
+	}
+	return filepath.Join(dir, host, filepath.FromSlash(path.Clean("/"+p))), nil
+}
+
+// RenderFetcher fetches pages through an external renderer, e.g. a headless browser service
+// which runs the page's JavaScript, reached over a Unix socket or TCP.
+//
+// Each page is requested as GET /render?url=<url>. The renderer answers with the rendered
+// HTML, and may set X-Status-Code and X-Final-URL to the status and URL of the page itself.
+// Any status other than 200 from the renderer is reported as an error. Used by a scraper,
+// the rendered HTML is cut off at the scraper's maximum body size.
+type RenderFetcher struct {
+	client *http.Client
+	host   string
+}
+
+// NewRenderFetcher creates a fetcher for the renderer listening on address,
+// where network is "unix" or "tcp".
+func NewRenderFetcher(network, address string) *RenderFetcher {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+
+	host := address
+	if network == "unix" {
+		host = "renderer"
+	}
+	return &RenderFetcher{
+		client: &http.Client{Transport: transport, Timeout: ScrapeTimeoutDuration * 3},
+		host:   host,
+	}
+}
+
+func (rf *RenderFetcher) Fetch(ctx context.Context, rawURL string) Result {
+	return rf.fetchLimited(ctx, rawURL, DefaultMaxBodySize)
+}
+
+func (rf *RenderFetcher) fetchLimited(ctx context.Context, rawURL string, maxBodySize int64) Result {
+	endpoint := "http://" + rf.host + "/render?url=" + url.QueryEscape(rawURL)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Result{URL: rawURL, Error: err}
+	}
+
+	resp, err := rf.client.Do(req)
+	if err != nil {
+		return Result{URL: rawURL, Error: fmt.Errorf("renderer: %w", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 4<<10))
+		return Result{URL: rawURL, Error: fmt.Errorf("renderer: %s", resp.Status)}
+	}
+
+	result := Result{
+		URL:        rawURL,
+		FinalURL:   rawURL,
+		StatusCode: http.StatusOK,
+		Header:     resp.Header,
+	}
+	if finalURL := resp.Header.Get("X-Final-URL"); finalURL != "" {
+		result.FinalURL = finalURL
+	}
+	if status, err := strconv.Atoi(resp.Header.Get("X-Status-Code")); err == nil {
+		result.StatusCode = status
+	}
+	result.Content, result.Truncated, result.received, result.Error = readBody(resp, maxBodySize)
+	return result
+}
@@ -0,0 +1,248 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// namedFetcher answers every URL with its name as the content.
+func namedFetcher(name string) scraper.Fetcher {
+	return scraper.ScrapeFunc(func(ctx context.Context, url string) scraper.Result {
+		return scraper.Result{URL: url, Content: name, StatusCode: http.StatusOK}
+	})
+}
+
+// offlineFetcher answers every URL with "offline" without visiting its site.
+type offlineFetcher struct{}
+
+func (offlineFetcher) Fetch(ctx context.Context, url string) scraper.Result {
+	return scraper.Result{URL: url, Content: "offline", StatusCode: http.StatusOK}
+}
+
+func (offlineFetcher) Offline() bool {
+	return true
+}
+
+func TestRoutes(t *testing.T) {
+	s := newTestScraper(t, 1)
+	s.SetFetcher(namedFetcher("default"))
+	s.SetRoutes(
+		scraper.Route{Hosts: []string{"App.Example.com"}, Fetcher: namedFetcher("app")},
+		scraper.Route{Pattern: regexp.MustCompile(`/archive/`), Fetcher: namedFetcher("archive")},
+		scraper.Route{Hosts: []string{"example.com"}, Pattern: regexp.MustCompile(`\.pdf$`), Fetcher: namedFetcher("pdf")},
+		scraper.Route{Fetcher: namedFetcher("never")},
+	)
+
+	expect := map[string]string{
+		"https://app.example.com/":           "app",
+		"https://eu.app.example.com/":        "app",
+		"https://app.example.com/archive/1":  "app",
+		"https://example.com/archive/1":      "archive",
+		"https://example.com/report.pdf":     "pdf",
+		"https://other.org/report.pdf":       "default",
+		"https://notapp.example.com/":        "default",
+		"https://example.com/archive.tar.gz": "default",
+	}
+
+	var urls []string
+	for url := range expect {
+		urls = append(urls, url)
+	}
+	results, err := s.ScrapeURLs(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byURL(collect(t, results))
+	for url, fetcher := range expect {
+		if got[url].Content != fetcher {
+			t.Errorf("%s: expected the %s fetcher, got %q", url, fetcher, got[url].Content)
+		}
+	}
+}
+
+func TestFileFetcher(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"example.com/index.html":      "home",
+		"example.com/docs/index.html": "docs",
+		"example.com/docs/page.html":  "page",
+		"example.com/data.json":       `{"ok": true}`,
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fileURL := (&url.URL{Scheme: "file", Path: filepath.ToSlash(filepath.Join(dir, "example.com", "data.json"))}).String()
+
+	tests := []struct {
+		name          string
+		url           string
+		expectStatus  int
+		expectContent string
+		expectType    string
+		expectErr     bool
+	}{
+		{
+			name:          "root",
+			url:           "https://example.com/",
+			expectStatus:  http.StatusOK,
+			expectContent: "home",
+			expectType:    "text/html; charset=utf-8",
+		},
+		{
+			name:          "directory",
+			url:           "https://example.com/docs",
+			expectStatus:  http.StatusOK,
+			expectContent: "docs",
+		},
+		{
+			name:          "file",
+			url:           "https://example.com/docs/page.html",
+			expectStatus:  http.StatusOK,
+			expectContent: "page",
+		},
+		{
+			name:          "dot segments stay in the mirror",
+			url:           "https://example.com/../../docs/page.html",
+			expectStatus:  http.StatusOK,
+			expectContent: "page",
+		},
+		{
+			name:          "file URL",
+			url:           fileURL,
+			expectStatus:  http.StatusOK,
+			expectContent: `{"ok": true}`,
+			expectType:    "application/json",
+		},
+		{
+			name:         "missing file",
+			url:          "https://example.com/missing.html",
+			expectStatus: http.StatusNotFound,
+		},
+	}
+
+	ff := scraper.FileFetcher{Dir: dir}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := ff.Fetch(context.Background(), tt.url)
+			if result.Error != nil {
+				t.Fatalf("unexpected error: %v", result.Error)
+			}
+			if result.StatusCode != tt.expectStatus || result.Content != tt.expectContent {
+				t.Errorf("expected %d %q, got %d %q", tt.expectStatus, tt.expectContent, result.StatusCode, result.Content)
+			}
+			if tt.expectType != "" && result.Header.Get("Content-Type") != tt.expectType {
+				t.Errorf("expected Content-Type %q, got %q", tt.expectType, result.Header.Get("Content-Type"))
+			}
+		})
+	}
+
+	if result := (scraper.FileFetcher{}).Fetch(context.Background(), "https://example.com/"); result.Error == nil {
+		t.Error("expected an error for an http URL without a mirror")
+	}
+	if !ff.Offline() {
+		t.Error("expected FileFetcher to be offline")
+	}
+}
+
+func TestOfflineFetcherSkipsRobots(t *testing.T) {
+	srv, fetches := newRobotsSite(t, http.StatusOK, "User-agent: *\nDisallow: /\n")
+
+	s := newTestScraper(t, 1)
+	s.SetRespectRobots(true)
+	s.SetRoutes(scraper.Route{Pattern: regexp.MustCompile(`/offline/`), Fetcher: offlineFetcher{}})
+
+	results, err := s.ScrapeURLs(context.Background(), []string{srv.URL + "/offline/page", srv.URL + "/online/page"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := byURL(collect(t, results))
+
+	if offline := got[srv.URL+"/offline/page"]; offline.Error != nil || offline.Content != "offline" {
+		t.Errorf("expected robots.txt not to apply offline, got %q and %v", offline.Content, offline.Error)
+	}
+	if online := got[srv.URL+"/online/page"]; !errors.Is(online.Error, scraper.ErrDisallowedByRobots) {
+		t.Errorf("expected robots.txt to apply to other fetchers, got %v", online.Error)
+	}
+	if n := fetches.Load(); n != 1 {
+		t.Errorf("expected robots.txt to be fetched once, for the online URL, got %d", n)
+	}
+}
+
+// newRenderer serves a fake renderer on TCP, rendering every page with the given handler.
+func newRenderer(t *testing.T, render http.HandlerFunc) *scraper.RenderFetcher {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/render" || r.URL.Query().Get("url") == "" {
+			http.Error(w, "bad render request", http.StatusBadRequest)
+			return
+		}
+		render(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return scraper.NewRenderFetcher("tcp", srv.Listener.Addr().String())
+}
+
+func TestRenderFetcher(t *testing.T) {
+	rf := newRenderer(t, func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("url")
+		switch {
+		case strings.HasSuffix(page, "/moved"):
+			w.Header().Set("X-Final-URL", "https://example.com/new")
+			w.Header().Set("X-Status-Code", "404")
+			w.Write([]byte("<p>gone</p>"))
+		case strings.HasSuffix(page, "/fail"):
+			http.Error(w, "browser crashed", http.StatusBadGateway)
+		default:
+			w.Write([]byte("<p>rendered " + page + "</p>"))
+		}
+	})
+
+	result := rf.Fetch(context.Background(), "https://example.com/app")
+	if result.Error != nil || result.StatusCode != http.StatusOK || result.Content != "<p>rendered https://example.com/app</p>" {
+		t.Errorf("expected the rendered page, got %d %q and %v", result.StatusCode, result.Content, result.Error)
+	}
+
+	moved := rf.Fetch(context.Background(), "https://example.com/moved")
+	if moved.StatusCode != http.StatusNotFound || moved.FinalURL != "https://example.com/new" {
+		t.Errorf("expected the page's status and final URL, got %d and %q", moved.StatusCode, moved.FinalURL)
+	}
+
+	if failed := rf.Fetch(context.Background(), "https://example.com/fail"); failed.Error == nil {
+		t.Error("expected an error when the renderer fails")
+	}
+}
+
+func TestRenderFetcherMaxBodySize(t *testing.T) {
+	rf := newRenderer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("x", 100)))
+	})
+
+	s := newTestScraper(t, 1)
+	s.SetFetcher(rf)
+	if err := s.SetMaxBodySize(10); err != nil {
+		t.Fatal(err)
+	}
+
+	result := scrapeOne(t, s, "https://example.com/app")
+	if !result.Truncated || len(result.Content) != 10 {
+		t.Errorf("expected the rendered page cut off at 10 bytes, got %d bytes (truncated: %v)", len(result.Content), result.Truncated)
+	}
+}
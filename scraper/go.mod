@@ -2,4 +2,13 @@ module github.com/hiteshrepo/awesome-tools/scraper
 
 go 1.24.1
 
-require github.com/hiteshrepo/awesome-tools/rate-limiter v0.0.0-20250629044837-36a03e0d98ac
+require (
+	github.com/andybalholm/brotli v1.1.1
+	github.com/andybalholm/cascadia v1.3.3
+	github.com/hiteshrepo/awesome-tools/rate-limiter v0.0.0-20250629044837-36a03e0d98ac
+	golang.org/x/net v0.50.0
+)
+
+require golang.org/x/text v0.34.0 // indirect
+
+replace github.com/hiteshrepo/awesome-tools/rate-limiter => ../rate-limiter
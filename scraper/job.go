@@ -0,0 +1,398 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// JobStore persists the progress of a Job, so that it can resume after a restart.
+type JobStore interface {
+	// Load returns the URLs queued so far, in the order they were queued,
+	// and the set of those already completed.
+	Load() (queued []QueuedURL, done map[string]bool, err error)
+	// Queue records that a URL is due to be scraped.
+	Queue(u QueuedURL) error
+	// Complete records the result of a queued URL.
+	Complete(result Result) error
+	// Close releases the store.
+	Close() error
+}
+
+// QueuedURL is a URL waiting in the frontier of a job.
+type QueuedURL struct {
+	URL   string `json:"url"`
+	Depth int    `json:"depth"`
+}
+
+// Job is a scrape or crawl whose progress is kept in a JobStore. Running a job again
+// with the same store skips the URLs already completed and picks up the rest of the frontier.
+type Job struct {
+	scraper *RateLimitedScraper
+	store   JobStore
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewJob creates a job scraping with s and keeping its progress in store.
+func (s *RateLimitedScraper) NewJob(store JobStore) *Job {
+	return &Job{
+		scraper: s,
+		store:   store,
+	}
+}
+
+// ScrapeURLs scrapes the URLs which were not completed by an earlier run of the job,
+// like RateLimitedScraper.ScrapeURLs. Results of earlier runs are not sent again;
+// read them from the store.
+func (j *Job) ScrapeURLs(ctx context.Context, urls []string) (<-chan Result, error) {
+	state, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+
+	queued := make(map[string]bool, len(state.queued))
+	for _, q := range state.queued {
+		queued[q.URL] = true
+	}
+	for _, url := range urls {
+		if queued[url] {
+			continue
+		}
+		queued[url] = true
+		q := QueuedURL{URL: url}
+		if err := j.store.Queue(q); err != nil {
+			return nil, fmt.Errorf("queueing %s: %w", url, err)
+		}
+		state.queued = append(state.queued, q)
+	}
+
+	var pending []string
+	for _, q := range state.queued {
+		if !state.done[q.URL] {
+			pending = append(pending, q.URL)
+		}
+	}
+	return j.scraper.scrapeURLs(ctx, pending, state)
+}
+
+// Crawl crawls from the seed URLs like RateLimitedScraper.Crawl. If an earlier run of the job
+// was interrupted, it resumes with the frontier it left behind instead of starting over.
+func (j *Job) Crawl(ctx context.Context, seeds []string, opts CrawlOptions) (<-chan Result, error) {
+	state, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+	return j.scraper.crawl(ctx, seeds, opts, state)
+}
+
+// Err returns the first error the job had storing its progress. A job stops when that happens.
+func (j *Job) Err() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.err
+}
+
+func (j *Job) load() (*jobState, error) {
+	queued, done, err := j.store.Load()
+	if err != nil {
+		return nil, fmt.Errorf("loading job: %w", err)
+	}
+	return &jobState{
+		job:    j,
+		queued: queued,
+		done:   done,
+	}, nil
+}
+
+func (j *Job) fail(err error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.err == nil {
+		j.err = err
+	}
+}
+
+// jobState is the progress of a job as loaded from its store. A nil *jobState
+// is a scrape without a job, for which recording progress does nothing.
+type jobState struct {
+	job    *Job
+	queued []QueuedURL
+	done   map[string]bool
+}
+
+// queue records a URL added to the frontier. Returns false if it could not be stored.
+func (js *jobState) queue(q QueuedURL) bool {
+	if js == nil {
+		return true
+	}
+	if err := js.job.store.Queue(q); err != nil {
+		js.job.fail(fmt.Errorf("queueing %s: %w", q.URL, err))
+		return false
+	}
+	return true
+}
+
+// complete records a result. Returns false if it could not be stored.
+// A result which failed because ctx was cancelled is not recorded, so the URL
+// is fetched again when the job resumes.
+func (js *jobState) complete(ctx context.Context, result Result) bool {
+	if js == nil {
+		return true
+	}
+	if result.Error != nil && ctx.Err() != nil && errors.Is(result.Error, ctx.Err()) {
+		return true
+	}
+	if err := js.job.store.Complete(result); err != nil {
+		js.job.fail(fmt.Errorf("completing %s: %w", result.URL, err))
+		return false
+	}
+	return true
+}
+
+// FileStore is a JobStore keeping a job's progress in a JSON Lines file.
+// Every queued URL and every result is appended as one line, so a crash loses
+// at most the line being written, which is cut off when the file is opened again.
+type FileStore struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+	w    *bufio.Writer
+}
+
+var _ JobStore = (*FileStore)(nil)
+
+// fileRecord is one line of a FileStore.
+type fileRecord struct {
+	Queued *QueuedURL    `json:"queued,omitempty"`
+	Result *storedResult `json:"result,omitempty"`
+}
+
+// storedResult is a Result as written to a FileStore, with its errors as strings.
+// The raw content is left out, as the content holds the same page.
+type storedResult struct {
+	URL         string            `json:"url"`
+	FinalURL    string            `json:"final_url,omitempty"`
+	Content     string            `json:"content,omitempty"`
+	Truncated   bool              `json:"truncated,omitempty"`
+	StatusCode  int               `json:"status_code,omitempty"`
+	Header      http.Header       `json:"header,omitempty"`
+	Attempts    int               `json:"attempts,omitempty"`
+	Depth       int               `json:"depth,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	MainText    string            `json:"main_text,omitempty"`
+	Meta        *PageMeta         `json:"meta,omitempty"`
+	FromCache   bool              `json:"from_cache,omitempty"`
+	NotModified bool              `json:"not_modified,omitempty"`
+	FetchedAt   time.Time         `json:"fetched_at,omitzero"`
+	Error       string            `json:"error,omitempty"`
+	// ExtractError is the message of Result.ExtractError.
+	ExtractError string `json:"extract_error,omitempty"`
+}
+
+func newStoredResult(result Result) *storedResult {
+	stored := &storedResult{
+		URL:         result.URL,
+		FinalURL:    result.FinalURL,
+		Content:     result.Content,
+		Truncated:   result.Truncated,
+		StatusCode:  result.StatusCode,
+		Header:      result.Header,
+		Attempts:    result.Attempts,
+		Depth:       result.Depth,
+		Fields:      result.Fields,
+		MainText:    result.MainText,
+		Meta:        result.Meta,
+		FromCache:   result.FromCache,
+		NotModified: result.NotModified,
+		FetchedAt:   result.FetchedAt,
+	}
+	if result.Error != nil {
+		stored.Error = result.Error.Error()
+	}
+	if result.ExtractError != nil {
+		stored.ExtractError = result.ExtractError.Error()
+	}
+	return stored
+}
+
+// result restores the Result, with its errors as plain errors carrying the original messages.
+func (sr *storedResult) result() Result {
+	result := Result{
+		URL:         sr.URL,
+		FinalURL:    sr.FinalURL,
+		Content:     sr.Content,
+		Truncated:   sr.Truncated,
+		StatusCode:  sr.StatusCode,
+		Header:      sr.Header,
+		Attempts:    sr.Attempts,
+		Depth:       sr.Depth,
+		Fields:      sr.Fields,
+		MainText:    sr.MainText,
+		Meta:        sr.Meta,
+		FromCache:   sr.FromCache,
+		NotModified: sr.NotModified,
+		FetchedAt:   sr.FetchedAt,
+	}
+	if sr.Error != "" {
+		result.Error = errors.New(sr.Error)
+	}
+	if sr.ExtractError != "" {
+		result.ExtractError = errors.New(sr.ExtractError)
+	}
+	return result
+}
+
+// OpenFileStore opens the job file at path, creating it if needed.
+func OpenFileStore(path string) (*FileStore, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if err := truncateTornLine(file); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("repairing %s: %w", path, err)
+	}
+
+	return &FileStore{
+		path: path,
+		file: file,
+		w:    bufio.NewWriter(file),
+	}, nil
+}
+
+func (fs *FileStore) Load() ([]QueuedURL, map[string]bool, error) {
+	var queued []QueuedURL
+	done := make(map[string]bool)
+
+	err := fs.read(func(rec fileRecord) error {
+		if rec.Queued != nil {
+			queued = append(queued, *rec.Queued)
+		}
+		if rec.Result != nil {
+			done[rec.Result.URL] = true
+		}
+		return nil
+	})
+	return queued, done, err
+}
+
+func (fs *FileStore) Queue(u QueuedURL) error {
+	return fs.append(fileRecord{Queued: &u})
+}
+
+func (fs *FileStore) Complete(result Result) error {
+	return fs.append(fileRecord{Result: newStoredResult(result)})
+}
+
+// Results calls fn with every result stored so far, in the order they completed.
+// Errors are restored as plain errors carrying the original message.
+func (fs *FileStore) Results(fn func(Result) error) error {
+	return fs.read(func(rec fileRecord) error {
+		if rec.Result == nil {
+			return nil
+		}
+		return fn(rec.Result.result())
+	})
+}
+
+func (fs *FileStore) Close() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.w.Flush(); err != nil {
+		fs.file.Close()
+		return err
+	}
+	return fs.file.Close()
+}
+
+// truncateTornLine cuts off a last line left without its newline by a crash,
+// so the next record does not get appended onto it.
+func truncateTornLine(file *os.File) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	end := info.Size()
+	buf := make([]byte, 4096)
+	for end > 0 {
+		n := min(end, int64(len(buf)))
+		if _, err := file.ReadAt(buf[:n], end-n); err != nil {
+			return err
+		}
+		if i := bytes.LastIndexByte(buf[:n], '\n'); i >= 0 {
+			end = end - n + int64(i) + 1
+			break
+		}
+		end -= n
+	}
+
+	if end == info.Size() {
+		return nil
+	}
+	return file.Truncate(end)
+}
+
+// append writes one record and flushes it to the file.
+func (fs *FileStore) append(rec fileRecord) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	fs.w.Write(line)
+	fs.w.WriteByte('\n')
+	return fs.w.Flush()
+}
+
+// read calls fn with every complete record in the file.
+func (fs *FileStore) read(fn func(rec fileRecord) error) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if err := fs.w.Flush(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF {
+			// a line without a newline was cut off by a crash
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var rec fileRecord
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return fmt.Errorf("reading %s: %w", fs.path, err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
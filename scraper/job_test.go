@@ -0,0 +1,207 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// openStore opens a FileStore at path, closing it when the test ends.
+func openStore(t *testing.T, path string) *scraper.FileStore {
+	t.Helper()
+
+	store, err := scraper.OpenFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// storedResults returns every result in store.
+func storedResults(t *testing.T, store *scraper.FileStore) []scraper.Result {
+	t.Helper()
+
+	var results []scraper.Result
+	if err := store.Results(func(r scraper.Result) error {
+		results = append(results, r)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return results
+}
+
+func TestFileStoreRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.jsonl")
+
+	store := openStore(t, path)
+	failed := scraper.Result{
+		URL:        "https://example.com/broken",
+		StatusCode: http.StatusServiceUnavailable,
+		Header:     http.Header{"Retry-After": {"120"}},
+		Attempts:   3,
+		Depth:      1,
+		Meta:       &scraper.PageMeta{Title: "Down"},
+		Error:      errors.New("unexpected status 503"),
+	}
+	if err := store.Queue(scraper.QueuedURL{URL: failed.URL, Depth: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Complete(failed); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	store = openStore(t, path)
+	queued, done, err := store.Load()
+	if err != nil {
+		t.Fatalf("unexpected error loading a failed result: %v", err)
+	}
+	if len(queued) != 1 || queued[0] != (scraper.QueuedURL{URL: failed.URL, Depth: 1}) {
+		t.Errorf("expected the queued URL back, got %v", queued)
+	}
+	if !done[failed.URL] {
+		t.Errorf("expected %s to be done, got %v", failed.URL, done)
+	}
+
+	results := storedResults(t, store)
+	if len(results) != 1 {
+		t.Fatalf("expected one result, got %d", len(results))
+	}
+	got := results[0]
+	if got.Error == nil || got.Error.Error() != failed.Error.Error() {
+		t.Errorf("expected error %q, got %v", failed.Error, got.Error)
+	}
+	if got.StatusCode != failed.StatusCode || got.Attempts != failed.Attempts || got.Depth != failed.Depth {
+		t.Errorf("expected %+v, got %+v", failed, got)
+	}
+	if got.Header.Get("Retry-After") != "120" {
+		t.Errorf("expected the header back, got %v", got.Header)
+	}
+	if got.Meta == nil || got.Meta.Title != "Down" {
+		t.Errorf("expected the page meta back, got %+v", got.Meta)
+	}
+}
+
+func TestFileStoreTornLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.jsonl")
+	torn := `{"queued":{"url":"https://example.com/a","depth":0}}` + "\n" + `{"queued":{"url":"https://exa`
+	if err := os.WriteFile(path, []byte(torn), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	store := openStore(t, path)
+	if err := store.Queue(scraper.QueuedURL{URL: "https://example.com/b"}); err != nil {
+		t.Fatal(err)
+	}
+
+	queued, _, err := store.Load()
+	if err != nil {
+		t.Fatalf("unexpected error after a torn line: %v", err)
+	}
+	var urls []string
+	for _, q := range queued {
+		urls = append(urls, q.URL)
+	}
+	if expect := []string{"https://example.com/a", "https://example.com/b"}; !slices.Equal(urls, expect) {
+		t.Errorf("expected %v, got %v", expect, urls)
+	}
+}
+
+func TestJobResume(t *testing.T) {
+	var mu sync.Mutex
+	fetched := make(map[string]int)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path]++
+		mu.Unlock()
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "job.jsonl")
+	run := func(urls ...string) []scraper.Result {
+		store := openStore(t, path)
+		defer store.Close()
+
+		job := newTestScraper(t, 2).NewJob(store)
+		results, err := job.ScrapeURLs(context.Background(), urls)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := collect(t, results)
+		if err := job.Err(); err != nil {
+			t.Fatalf("unexpected job error: %v", err)
+		}
+		return got
+	}
+
+	if got := run(srv.URL+"/a", srv.URL+"/b"); len(got) != 2 {
+		t.Fatalf("expected 2 results in the first run, got %d", len(got))
+	}
+	got := run(srv.URL+"/a", srv.URL+"/b", srv.URL+"/c")
+	if len(got) != 1 || got[0].URL != srv.URL+"/c" {
+		t.Errorf("expected only the new URL to be scraped again, got %v", got)
+	}
+	for _, path := range []string{"/a", "/b", "/c"} {
+		if fetched[path] != 1 {
+			t.Errorf("%s: expected one fetch, got %d", path, fetched[path])
+		}
+	}
+}
+
+func TestJobCancelLeavesInFlightURLsPending(t *testing.T) {
+	started := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			close(started)
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "job.jsonl")
+	store := openStore(t, path)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	job := newTestScraper(t, 2).NewJob(store)
+	results, err := job.ScrapeURLs(ctx, []string{srv.URL + "/fast", srv.URL + "/slow"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-started
+	for result := range results {
+		if result.URL == srv.URL+"/fast" {
+			cancel()
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	_, done, err := openStore(t, path).Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !done[srv.URL+"/fast"] {
+		t.Error("expected the fetched URL to be done")
+	}
+	if done[srv.URL+"/slow"] {
+		t.Error("expected the URL cancelled in flight to be left for the next run")
+	}
+}
@@ -0,0 +1,115 @@
+package scraper
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// ExtractLinks returns the absolute, normalised http(s) URLs linked from an HTML page
+// fetched from pageURL, in document order and without duplicates.
+// Relative links are resolved against the page's <base href> if it has one.
+func ExtractLinks(pageURL string, body string) []string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		return nil
+	}
+
+	var links []string
+	seen := make(map[string]bool)
+
+	z := html.NewTokenizer(strings.NewReader(body))
+	for {
+		tt := z.Next()
+		if tt == html.ErrorToken {
+			return links
+		}
+		if tt != html.StartTagToken && tt != html.SelfClosingTagToken {
+			continue
+		}
+
+		name, hasAttr := z.TagName()
+		if !hasAttr {
+			continue
+		}
+		tag := string(name)
+		if tag != "a" && tag != "area" && tag != "base" {
+			continue
+		}
+
+		href, ok := attr(z, "href")
+		if !ok {
+			continue
+		}
+		ref, err := url.Parse(strings.TrimSpace(href))
+		if err != nil {
+			continue
+		}
+
+		if tag == "base" {
+			base = base.ResolveReference(ref)
+			continue
+		}
+
+		link, err := NormalizeURL(base.ResolveReference(ref).String())
+		if err != nil || seen[link] {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+	}
+}
+
+// NormalizeURL returns a canonical form of an http(s) URL, so that different spellings
+// of the same page are de-duplicated: the scheme and host are lowercased, default ports
+// and fragments are dropped, dot segments are resolved and an empty path becomes "/".
+func NormalizeURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q in %s", u.Scheme, rawURL)
+	}
+
+	host, port, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		host, port = u.Host, ""
+	}
+	host = strings.ToLower(host)
+	if (u.Scheme == "http" && port == "80") || (u.Scheme == "https" && port == "443") {
+		port = ""
+	}
+	if port != "" {
+		host = net.JoinHostPort(host, port)
+	} else if strings.Contains(host, ":") && !strings.HasPrefix(host, "[") {
+		host = "[" + host + "]" // IPv6 literal which lost its default port
+	}
+	u.Host = host
+
+	u.Fragment = ""
+	u.RawFragment = ""
+	u = u.ResolveReference(&url.URL{})
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u.String(), nil
+}
+
+// attr returns the value of the named attribute of the current tag.
+func attr(z *html.Tokenizer, name string) (string, bool) {
+	for {
+		key, val, more := z.TagAttr()
+		if string(key) == name {
+			return string(val), true
+		}
+		if !more {
+			return "", false
+		}
+	}
+}
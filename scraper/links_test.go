@@ -0,0 +1,110 @@
+package scraper_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+func TestExtractLinks(t *testing.T) {
+	tests := []struct {
+		name        string
+		pageURL     string
+		body        string
+		expectLinks []string
+	}{
+		{
+			name:    "relative and absolute links",
+			pageURL: "https://example.com/docs/intro",
+			body: `<a href="setup">Setup</a>
+				<a href="/about#team">About</a>
+				<a href="https://other.org">Other</a>`,
+			expectLinks: []string{
+				"https://example.com/docs/setup",
+				"https://example.com/about",
+				"https://other.org/",
+			},
+		},
+		{
+			name:        "base href",
+			pageURL:     "https://example.com/a/b",
+			body:        `<base href="https://cdn.example.com/root/"><a href="page">Page</a>`,
+			expectLinks: []string{"https://cdn.example.com/root/page"},
+		},
+		{
+			name:        "duplicates and non-http links are dropped",
+			pageURL:     "https://example.com/",
+			body:        `<a href="/x">1</a><a href="/x#top">2</a><a href="mailto:a@example.com">3</a><a href="javascript:void(0)">4</a>`,
+			expectLinks: []string{"https://example.com/x"},
+		},
+		{
+			name:        "image map areas",
+			pageURL:     "https://example.com/",
+			body:        `<map><area href="/region"></map>`,
+			expectLinks: []string{"https://example.com/region"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			links := scraper.ExtractLinks(tt.pageURL, tt.body)
+			if !slices.Equal(links, tt.expectLinks) {
+				t.Errorf("expected %v, got %v", tt.expectLinks, links)
+			}
+		})
+	}
+}
+
+func TestNormalizeURL(t *testing.T) {
+	tests := []struct {
+		name      string
+		rawURL    string
+		expectURL string
+		expectErr bool
+	}{
+		{
+			name:      "lowercases scheme and host",
+			rawURL:    "HTTPS://Example.COM/Path",
+			expectURL: "https://example.com/Path",
+		},
+		{
+			name:      "drops default port and fragment",
+			rawURL:    "http://example.com:80/a#section",
+			expectURL: "http://example.com/a",
+		},
+		{
+			name:      "keeps other ports",
+			rawURL:    "http://example.com:8080",
+			expectURL: "http://example.com:8080/",
+		},
+		{
+			name:      "resolves dot segments",
+			rawURL:    "https://example.com/a/./b/../c",
+			expectURL: "https://example.com/a/c",
+		},
+		{
+			name:      "rejects other schemes",
+			rawURL:    "ftp://example.com/file",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := scraper.NormalizeURL(tt.rawURL)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.expectURL {
+				t.Errorf("expected %q, got %q", tt.expectURL, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,252 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RequestTemplate customises the requests sent by the default scrape function.
+// Zero fields leave the default behaviour in place.
+type RequestTemplate struct {
+	// Method is the HTTP method, GET by default.
+	Method string
+	// Body is sent with every request, e.g. a form or JSON for POST. Set its Content-Type in Header.
+	Body []byte
+	// Header is added to every request, replacing the default value of headers it names.
+	Header http.Header
+	// UserAgents, if set, replaces the scraper's User-Agent with one of them picked at random per request.
+	UserAgents []string
+	// Username and Password, if set, are sent with basic authentication.
+	Username string
+	Password string
+	// BearerToken, if set, is sent as an "Authorization: Bearer" header.
+	BearerToken string
+	// Jar keeps the cookies set by responses and sends them back, e.g. one made with cookiejar.New.
+	Jar http.CookieJar
+	// Proxy is the proxy requests go through, with an http, https or socks5 scheme.
+	// Defaults to the proxy set in the environment.
+	Proxy *url.URL
+	// Timeout caps every request, including reading the body. Defaults to ScrapeTimeoutDuration.
+	Timeout time.Duration
+}
+
+// merge returns t with the non-zero fields of o applied over it. Headers are merged,
+// with those of o replacing headers of the same name, however either spells it.
+func (t RequestTemplate) merge(o RequestTemplate) RequestTemplate {
+	if o.Method != "" {
+		t.Method = o.Method
+	}
+	if o.Body != nil {
+		t.Body = o.Body
+	}
+	if len(o.Header) > 0 {
+		header := make(http.Header, len(t.Header)+len(o.Header))
+		for _, h := range []http.Header{t.Header, o.Header} {
+			for key, values := range h {
+				header[http.CanonicalHeaderKey(key)] = slices.Clone(values)
+			}
+		}
+		t.Header = header
+	}
+	if len(o.UserAgents) > 0 {
+		t.UserAgents = o.UserAgents
+	}
+	if o.Username != "" || o.Password != "" {
+		t.Username, t.Password = o.Username, o.Password
+	}
+	if o.BearerToken != "" {
+		t.BearerToken = o.BearerToken
+	}
+	if o.Jar != nil {
+		t.Jar = o.Jar
+	}
+	if o.Proxy != nil {
+		t.Proxy = o.Proxy
+	}
+	if o.Timeout != 0 {
+		t.Timeout = o.Timeout
+	}
+	return t
+}
+
+// plainGet reports whether the template sends bodiless GETs, the only requests whose responses are cached.
+func (t RequestTemplate) plainGet() bool {
+	return (t.Method == "" || t.Method == http.MethodGet) && t.Body == nil
+}
+
+// newRequest builds the request for rawURL from the template.
+func (t RequestTemplate) newRequest(ctx context.Context, rawURL, userAgent string) (*http.Request, error) {
+	method := t.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+
+	var body io.Reader
+	if t.Body != nil {
+		body = bytes.NewReader(t.Body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, body)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	if len(t.UserAgents) > 0 {
+		userAgent = t.UserAgents[rand.IntN(len(t.UserAgents))]
+	}
+	req.Header.Set("User-Agent", userAgent)
+	for key, values := range t.Header {
+		req.Header[http.CanonicalHeaderKey(key)] = values
+	}
+
+	if t.Username != "" || t.Password != "" {
+		req.SetBasicAuth(t.Username, t.Password)
+	}
+	if t.BearerToken != "" {
+		req.Header.Set("Authorization", "Bearer "+t.BearerToken)
+	}
+	return req, nil
+}
+
+// newGetRequest builds a GET for rawURL from its template, e.g. for robots.txt or a sitemap, with the
+// template's headers, credentials and cookies but not its method and body. Returns the client to send it with.
+func (s *RateLimitedScraper) newGetRequest(ctx context.Context, rawURL string) (*http.Request, *http.Client, error) {
+	t := s.template(rawURL)
+	t.Method, t.Body = "", nil
+
+	req, err := t.newRequest(ctx, rawURL, s.userAgent)
+	if err != nil {
+		return nil, nil, err
+	}
+	return req, s.clientFor(t), nil
+}
+
+// clientFor returns the client sending the requests of t: the scraper's own,
+// unless t needs one with its own cookie jar, proxy or timeout.
+func (s *RateLimitedScraper) clientFor(t RequestTemplate) *http.Client {
+	if t.Jar != nil || t.Proxy != nil || t.Timeout != 0 {
+		return s.clients.client(t)
+	}
+	return s.client
+}
+
+// template returns the request template for rawURL: the scraper's template with that of
+// the most specific host configured for the URL's host or one of its parent domains applied over it.
+func (s *RateLimitedScraper) template(rawURL string) RequestTemplate {
+	t := s.requestTemplate
+	if len(s.hostTemplates) == 0 {
+		return t
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return t
+	}
+	host := strings.ToLower(u.Hostname())
+	for {
+		if ht, ok := s.hostTemplates[host]; ok {
+			return t.merge(ht)
+		}
+		_, parent, ok := strings.Cut(host, ".")
+		if !ok {
+			return t
+		}
+		host = parent
+	}
+}
+
+// clientKey identifies the settings which need an http.Client of their own.
+type clientKey struct {
+	jar     any // see jarKey
+	proxy   string
+	timeout time.Duration
+}
+
+// jarPointer identifies a cookie jar of a type which is not comparable by its address.
+type jarPointer uintptr
+
+// jarKey returns what identifies jar in a clientKey. Jars of a comparable type, e.g. a *cookiejar.Jar,
+// are used as they are, and those of reference types such as maps and funcs by their address.
+// Returns false for a jar without an identity, e.g. a struct holding a map, which cannot be pooled.
+func jarKey(jar http.CookieJar) (any, bool) {
+	if jar == nil {
+		return nil, true
+	}
+
+	v := reflect.ValueOf(jar)
+	if v.Comparable() {
+		return jar, true
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Func, reflect.Slice:
+		return jarPointer(v.Pointer()), true
+	}
+	return nil, false
+}
+
+// clientPool keeps one http.Client per combination of cookie jar, proxy and timeout
+// in use, and one transport per proxy, so connections are reused between requests with the same settings.
+type clientPool struct {
+	mu         sync.Mutex
+	clients    map[clientKey]*http.Client
+	transports map[string]*http.Transport // by proxy URL
+}
+
+// client returns the client for the template, creating it on first use.
+func (p *clientPool) client(t RequestTemplate) *http.Client {
+	jar, pooled := jarKey(t.Jar)
+	key := clientKey{jar: jar, timeout: t.Timeout}
+	if t.Proxy != nil {
+		key.proxy = t.Proxy.String()
+	}
+	if key.timeout == 0 {
+		key.timeout = ScrapeTimeoutDuration
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if c, ok := p.clients[key]; ok && pooled {
+		return c
+	}
+
+	c := &http.Client{Jar: t.Jar, Timeout: key.timeout}
+	if t.Proxy != nil {
+		c.Transport = p.transport(t.Proxy)
+	}
+	if !pooled {
+		// a jar without an identity gets a client of its own every time, sharing the transport
+		return c
+	}
+
+	if p.clients == nil {
+		p.clients = make(map[clientKey]*http.Client)
+	}
+	p.clients[key] = c
+	return c
+}
+
+// transport returns the transport going through proxy, creating it on first use. p.mu must be held.
+func (p *clientPool) transport(proxy *url.URL) *http.Transport {
+	if transport, ok := p.transports[proxy.String()]; ok {
+		return transport
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyURL(proxy)
+	if p.transports == nil {
+		p.transports = make(map[string]*http.Transport)
+	}
+	p.transports[proxy.String()] = transport
+	return transport
+}
@@ -0,0 +1,204 @@
+package scraper_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// echoRequests serves every request back as its method and body, and records the requests received.
+func echoRequests(t *testing.T) (*httptest.Server, func() []*http.Request) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var received []*http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, r)
+		mu.Unlock()
+		w.Write([]byte(r.Method + " " + string(body)))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, func() []*http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return slices.Clone(received)
+	}
+}
+
+func TestRequestTemplate(t *testing.T) {
+	srv, received := echoRequests(t)
+
+	s := newTestScraper(t, 1)
+	s.SetRequestTemplate(scraper.RequestTemplate{
+		Method:      http.MethodPost,
+		Body:        []byte(`{"q": 1}`),
+		Header:      http.Header{"content-type": {"application/json"}},
+		UserAgents:  []string{"agent-a", "agent-b"},
+		BearerToken: "secret",
+	})
+
+	result := scrapeOne(t, s, srv.URL)
+	if result.Content != `POST {"q": 1}` {
+		t.Errorf("expected the method and body of the template, got %q", result.Content)
+	}
+	req := received()[0]
+	if req.Header.Get("Content-Type") != "application/json" {
+		t.Errorf("expected the template's header, got %v", req.Header)
+	}
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("expected the bearer token, got %q", req.Header.Get("Authorization"))
+	}
+	if ua := req.Header.Get("User-Agent"); ua != "agent-a" && ua != "agent-b" {
+		t.Errorf("expected one of the template's user agents, got %q", ua)
+	}
+}
+
+func TestHostTemplate(t *testing.T) {
+	srv, received := echoRequests(t)
+	// the same server reached as localhost gets the host template, as 127.0.0.1 it does not
+	localURL := strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)
+
+	s := newTestScraper(t, 1)
+	s.SetRequestTemplate(scraper.RequestTemplate{
+		Header:   http.Header{"x-api-key": {"default"}, "Accept": {"text/html"}},
+		Username: "user",
+		Password: "pass",
+	})
+	s.SetHostTemplate("LOCALHOST", scraper.RequestTemplate{
+		Header: http.Header{"X-Api-Key": {"local"}},
+	})
+
+	scrapeOne(t, s, srv.URL+"/ip")
+	scrapeOne(t, s, localURL+"/named")
+
+	for _, req := range received() {
+		expectKey := "default"
+		if req.URL.Path == "/named" {
+			expectKey = "local"
+		}
+		if keys := req.Header.Values("X-Api-Key"); !slices.Equal(keys, []string{expectKey}) {
+			t.Errorf("%s: expected X-Api-Key %q only, got %v", req.URL.Path, expectKey, keys)
+		}
+		if req.Header.Get("Accept") != "text/html" {
+			t.Errorf("%s: expected the scraper's headers to be kept, got %v", req.URL.Path, req.Header)
+		}
+		if user, pass, ok := req.BasicAuth(); !ok || user != "user" || pass != "pass" {
+			t.Errorf("%s: expected basic authentication, got %q %q", req.URL.Path, user, pass)
+		}
+	}
+}
+
+// mapJar is a cookie jar which is not comparable, as it is a map.
+type mapJar map[string][]*http.Cookie
+
+func (j mapJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j[u.Host] = append(j[u.Host], cookies...)
+}
+
+func (j mapJar) Cookies(u *url.URL) []*http.Cookie {
+	return j[u.Host]
+}
+
+// structJar is a cookie jar which is not comparable, and has no address to tell it by either.
+type structJar struct {
+	cookies map[string][]*http.Cookie
+}
+
+func (j structJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.cookies[u.Host] = append(j.cookies[u.Host], cookies...)
+}
+
+func (j structJar) Cookies(u *url.URL) []*http.Cookie {
+	return j.cookies[u.Host]
+}
+
+func TestRequestTemplateJar(t *testing.T) {
+	stdJar, err := cookiejar.New(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		jar  http.CookieJar
+	}{
+		{name: "cookiejar", jar: stdJar},
+		{name: "map", jar: mapJar{}},
+		{name: "struct holding a map", jar: structJar{cookies: map[string][]*http.Cookie{}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/login" {
+					http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+					return
+				}
+				if c, err := r.Cookie("session"); err == nil {
+					w.Write([]byte(c.Value))
+				}
+			}))
+			defer srv.Close()
+
+			s := newTestScraper(t, 1)
+			s.SetRequestTemplate(scraper.RequestTemplate{Jar: tt.jar})
+
+			scrapeOne(t, s, srv.URL+"/login")
+			if result := scrapeOne(t, s, srv.URL+"/account"); result.Content != "abc" {
+				t.Errorf("expected the cookie set by the first response to be sent back, got %q", result.Content)
+			}
+		})
+	}
+}
+
+func TestRequestTemplateAppliesToRobotsAndSitemaps(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "secret" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "robots.txt and sitemaps are read with GET", http.StatusMethodNotAllowed)
+			return
+		}
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.Write([]byte("User-agent: *\nDisallow: /private\n"))
+		case "/sitemap.xml":
+			w.Write([]byte(`<urlset><url><loc>https://example.com/page</loc></url></urlset>`))
+		}
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	s.SetRespectRobots(true)
+	s.SetRequestTemplate(scraper.RequestTemplate{
+		Method: http.MethodPost,
+		Header: http.Header{"X-Api-Key": {"secret"}},
+	})
+
+	// a 403 for robots.txt would allow everything, so the private page shows it was read
+	if result := scrapeOne(t, s, srv.URL+"/private"); result.Error == nil {
+		t.Error("expected robots.txt to be fetched with the template's headers")
+	}
+
+	seeds, err := s.Sitemap(context.Background(), srv.URL+"/sitemap.xml", scraper.SeedOptions{})
+	if err != nil {
+		t.Fatalf("expected the sitemap to be fetched with the template's headers, got %v", err)
+	}
+	if len(seeds) != 1 || seeds[0].URL != "https://example.com/page" {
+		t.Errorf("expected the sitemap's URL, got %v", seeds)
+	}
+}
@@ -0,0 +1,110 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+// RetryPolicy decides whether and when a failed scrape is attempted again.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts per URL, including the first. 1 disables retries.
+	MaxAttempts int
+	// BaseDelay is the backoff cap before the first retry. It doubles after every attempt.
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff between two attempts.
+	MaxDelay time.Duration
+	// RetryableStatus lists the HTTP status codes worth retrying.
+	// Network errors and timeouts are always retried.
+	RetryableStatus []int
+	// MaxRetryAfter is the longest Retry-After the scraper honours. A response asking
+	// to wait longer is not retried. 0 means any Retry-After is honoured.
+	MaxRetryAfter time.Duration
+}
+
+// DefaultRetryPolicy retries timeouts, network errors and transient HTTP statuses up to 3 times in all.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   500 * time.Millisecond,
+	MaxDelay:    30 * time.Second,
+	RetryableStatus: []int{
+		http.StatusRequestTimeout,
+		http.StatusTooEarly,
+		http.StatusTooManyRequests,
+		http.StatusInternalServerError,
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+	MaxRetryAfter: 5 * time.Minute,
+}
+
+// retryDelay reports whether result should be retried after the given attempt (counting from 1),
+// and how long to wait first. The wait is the Retry-After of the response if it has one,
+// and otherwise a random backoff between 0 and BaseDelay*2^(attempt-1), capped at MaxDelay.
+func (p RetryPolicy) retryDelay(result Result, attempt int) (time.Duration, bool) {
+	if attempt >= p.MaxAttempts || !p.retryable(result) {
+		return 0, false
+	}
+
+	if retryAfter, ok := rlm.ParseRetryAfter(result.Header.Get("Retry-After"), time.Now()); ok {
+		if p.MaxRetryAfter > 0 && retryAfter > p.MaxRetryAfter {
+			return 0, false
+		}
+		return retryAfter, true
+	}
+
+	backoff := p.BaseDelay << (attempt - 1)
+	if backoff <= 0 || (p.MaxDelay > 0 && backoff > p.MaxDelay) {
+		// also guards against the shift overflowing
+		backoff = p.MaxDelay
+	}
+	if backoff <= 0 {
+		return 0, true
+	}
+	return rand.N(backoff + 1), true
+}
+
+func (p RetryPolicy) retryable(result Result) bool {
+	if result.Error != nil {
+		return retryableError(result.Error)
+	}
+	return slices.Contains(p.RetryableStatus, result.StatusCode)
+}
+
+// retryableError reports whether err looks transient, such as a timeout or a dropped connection.
+func retryableError(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// sleep waits for d or until the context is done. Returns false if the context is done.
+func sleep(ctx context.Context, d time.Duration) bool {
+	if d <= 0 {
+		return ctx.Err() == nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,186 @@
+package scraper_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// fastRetryPolicy is DefaultRetryPolicy with backoffs short enough for tests.
+func fastRetryPolicy() scraper.RetryPolicy {
+	p := scraper.DefaultRetryPolicy
+	p.BaseDelay = time.Millisecond
+	p.MaxDelay = 5 * time.Millisecond
+	p.MaxRetryAfter = time.Second
+	return p
+}
+
+// scrapeOne scrapes a single URL with s and returns its result.
+func scrapeOne(t *testing.T, s *scraper.RateLimitedScraper, url string) scraper.Result {
+	t.Helper()
+
+	results, err := s.ScrapeURLs(context.Background(), []string{url})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, results)
+	if len(got) != 1 {
+		t.Fatalf("expected one result, got %d", len(got))
+	}
+	return got[0]
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name           string
+		failures       int // requests answered with status before succeeding
+		status         int
+		retryAfter     string
+		expectAttempts int
+		expectStatus   int
+	}{
+		{
+			name:           "transient status is retried",
+			failures:       2,
+			status:         http.StatusServiceUnavailable,
+			expectAttempts: 3,
+			expectStatus:   http.StatusOK,
+		},
+		{
+			name:           "gives up after max attempts",
+			failures:       5,
+			status:         http.StatusBadGateway,
+			expectAttempts: 3,
+			expectStatus:   http.StatusBadGateway,
+		},
+		{
+			name:           "permanent status is not retried",
+			failures:       1,
+			status:         http.StatusNotFound,
+			expectAttempts: 1,
+			expectStatus:   http.StatusNotFound,
+		},
+		{
+			name:           "retry-after is honoured",
+			failures:       1,
+			status:         http.StatusTooManyRequests,
+			retryAfter:     "0",
+			expectAttempts: 2,
+			expectStatus:   http.StatusOK,
+		},
+		{
+			name:           "retry-after beyond the maximum is not waited for",
+			failures:       1,
+			status:         http.StatusTooManyRequests,
+			retryAfter:     "3600",
+			expectAttempts: 1,
+			expectStatus:   http.StatusTooManyRequests,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if int(requests.Add(1)) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(tt.status)
+					return
+				}
+				w.Write([]byte("ok"))
+			}))
+			defer srv.Close()
+
+			s := newTestScraper(t, 1)
+			s.SetRetryPolicy(fastRetryPolicy())
+
+			result := scrapeOne(t, s, srv.URL)
+			if result.Attempts != tt.expectAttempts {
+				t.Errorf("expected %d attempts, got %d", tt.expectAttempts, result.Attempts)
+			}
+			if result.StatusCode != tt.expectStatus {
+				t.Errorf("expected status %d, got %d", tt.expectStatus, result.StatusCode)
+			}
+			if got := int(requests.Load()); got != tt.expectAttempts {
+				t.Errorf("expected %d requests, got %d", tt.expectAttempts, got)
+			}
+		})
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// drop the connection without a response
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	s.SetRetryPolicy(fastRetryPolicy())
+
+	result := scrapeOne(t, s, srv.URL)
+	if result.Error != nil || result.Content != "ok" {
+		t.Fatalf("expected the retry to succeed, got content %q and error %v", result.Content, result.Error)
+	}
+	if result.Attempts != 2 {
+		t.Errorf("expected 2 attempts, got %d", result.Attempts)
+	}
+}
+
+func TestNoRetryByDefault(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	result := scrapeOne(t, newTestScraper(t, 1), srv.URL)
+	if result.Attempts != 1 || requests.Load() != 1 {
+		t.Errorf("expected a single attempt, got %d attempts and %d requests", result.Attempts, requests.Load())
+	}
+}
+
+func TestRetryStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	p := fastRetryPolicy()
+	p.MaxAttempts = 100
+	p.BaseDelay = time.Hour
+	p.MaxDelay = time.Hour
+	s.SetRetryPolicy(p)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := s.ScrapeURLs(ctx, []string{srv.URL})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the hour-long backoff is cut short by the cancellation, so results is closed promptly
+	time.AfterFunc(50*time.Millisecond, cancel)
+	for _, result := range collect(t, results) {
+		if result.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("expected the last response, got status %d", result.StatusCode)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package scraper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+)
+
+// DefaultUserAgent is sent with every request and used to pick robots.txt rules,
+// unless changed with SetUserAgent.
+const DefaultUserAgent = "awesome-tools-scraper/1.0"
+
+// ErrDisallowedByRobots is the error of a Result whose URL the site's robots.txt does not allow fetching.
+var ErrDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+const (
+	// robotsTTL is how long a fetched robots.txt is cached.
+	robotsTTL = 24 * time.Hour
+	// robotsErrorTTL is how long a host whose robots.txt could not be fetched is treated as disallowed.
+	robotsErrorTTL = time.Minute
+	// maxRobotsSize is the largest robots.txt read; the rest is ignored, as RFC 9309 permits.
+	maxRobotsSize = 500 << 10
+)
+
+// robotsCache fetches and caches the robots.txt rules of every host.
+type robotsCache struct {
+	// newRequest builds the request for a robots.txt URL and returns the client to send it with.
+	newRequest func(ctx context.Context, rawURL string) (*http.Request, *http.Client, error)
+	// wait blocks until the limiters of the scraper permit fetching a robots.txt URL.
+	wait      func(ctx context.Context, rawURL string) error
+	userAgent string
+
+	mu      sync.Mutex
+	entries map[string]*robotsEntry // by scheme and host
+}
+
+type robotsEntry struct {
+	ready      chan struct{} // closed once the rules are fetched, or the fetch is abandoned
+	abandoned  bool          // given up on without an answer, and not cached; set before ready is closed
+	rules      *robotsRules
+	crawlDelay *rlm.TokenBucket // nil if the site does not ask for a crawl delay
+	expires    time.Time
+}
+
+func newRobotsCache(
+	newRequest func(ctx context.Context, rawURL string) (*http.Request, *http.Client, error),
+	wait func(ctx context.Context, rawURL string) error,
+	userAgent string) *robotsCache {
+	return &robotsCache{
+		newRequest: newRequest,
+		wait:       wait,
+		userAgent:  userAgent,
+		entries:    make(map[string]*robotsEntry),
+	}
+}
+
+// check reports whether rawURL may be fetched, along with the limiter enforcing
+// the host's Crawl-delay, if any. robots.txt is fetched on first use of a host.
+// URLs other than http(s), e.g. local files, are always allowed.
+func (rc *robotsCache) check(ctx context.Context, rawURL string) (bool, rlm.Limiter, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false, nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return true, nil, nil
+	}
+
+	entry, err := rc.entry(ctx, u)
+	if err != nil {
+		return false, nil, err
+	}
+
+	var limiter rlm.Limiter
+	if entry.crawlDelay != nil {
+		limiter = entry.crawlDelay
+	}
+	return entry.rules.allowed(u.RequestURI()), limiter, nil
+}
+
+// entry returns the cached rules for the host of u, fetching them if needed.
+// Concurrent callers for the same host share one fetch. If the caller making it gives up,
+// the others are not left with its failure: one of them fetches robots.txt again.
+func (rc *robotsCache) entry(ctx context.Context, u *url.URL) (*robotsEntry, error) {
+	key := u.Scheme + "://" + u.Host
+
+	for {
+		rc.mu.Lock()
+		entry, ok := rc.entries[key]
+		if ok {
+			select {
+			case <-entry.ready:
+				if time.Now().After(entry.expires) {
+					ok = false
+				}
+			default:
+			}
+		}
+		if !ok {
+			entry = &robotsEntry{ready: make(chan struct{})}
+			rc.entries[key] = entry
+			rc.mu.Unlock()
+
+			if err := rc.fetch(ctx, key, entry); err != nil {
+				return nil, err
+			}
+			return entry, nil
+		}
+		rc.mu.Unlock()
+
+		select {
+		case <-entry.ready:
+			if !entry.abandoned {
+				return entry, nil
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// fetch downloads robots.txt for the site at origin into entry, once the limiters permit it.
+// Following RFC 9309, a missing robots.txt allows everything, and one which cannot be fetched
+// disallows everything. If the context is done or a limiter gives up before there is an answer,
+// which says nothing about the site, entry is abandoned rather than cached and the error is returned.
+func (rc *robotsCache) fetch(ctx context.Context, origin string, entry *robotsEntry) error {
+	defer close(entry.ready)
+
+	entry.rules = &robotsRules{}
+	entry.expires = time.Now().Add(robotsErrorTTL)
+	disallowAll := &robotsRules{rules: []robotsRule{{pattern: "/"}}}
+
+	if err := rc.wait(ctx, origin+"/robots.txt"); err != nil {
+		rc.abandon(origin, entry)
+		return err
+	}
+
+	req, client, err := rc.newRequest(ctx, origin+"/robots.txt")
+	if err != nil {
+		entry.rules = disallowAll
+		return nil
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			rc.abandon(origin, entry)
+			return ctx.Err()
+		}
+		entry.rules = disallowAll
+		return nil
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		entry.rules = disallowAll
+		return nil
+	case resp.StatusCode >= 400:
+		entry.expires = time.Now().Add(robotsTTL)
+		return nil
+	case resp.StatusCode >= 300:
+		// the client follows redirects itself, so this is a redirect loop or a missing Location
+		entry.rules = disallowAll
+		return nil
+	}
+
+	body, err := decompress(resp.Body, resp.Header.Get("Content-Encoding"))
+	if errors.Is(err, io.EOF) {
+		// an empty robots.txt allows everything
+		entry.expires = time.Now().Add(robotsTTL)
+		return nil
+	}
+	if err != nil {
+		entry.rules = disallowAll
+		return nil
+	}
+
+	entry.rules = parseRobots(io.LimitReader(body, maxRobotsSize), rc.userAgent)
+	entry.expires = time.Now().Add(robotsTTL)
+	if entry.rules.crawlDelay > 0 {
+		entry.crawlDelay = rlm.NewTokenBucket(1, entry.rules.crawlDelay, 1)
+	}
+	return nil
+}
+
+// abandon marks the fetch of entry as given up on and removes it, so the next caller fetches again.
+func (rc *robotsCache) abandon(origin string, entry *robotsEntry) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+
+	entry.abandoned = true
+	if rc.entries[origin] == entry {
+		delete(rc.entries, origin)
+	}
+}
+
+// robotsRules are the rules of the robots.txt group matching our user agent.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	sitemaps   []string
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string // path pattern, where * matches any sequence and a trailing $ anchors the end
+}
+
+// allowed reports whether path may be fetched. The longest matching rule wins,
+// and Allow wins over Disallow between rules of the same length.
+func (r *robotsRules) allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+
+	best, allow := -1, true
+	for _, rule := range r.rules {
+		if !matchRobotsPattern(rule.pattern, path) {
+			continue
+		}
+		if n := len(rule.pattern); n > best || (n == best && rule.allow) {
+			best, allow = n, rule.allow
+		}
+	}
+	return allow
+}
+
+// parseRobots parses a robots.txt, keeping the rules of the group for userAgent,
+// or the * group if no group names it. Several groups naming the agent are merged.
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	product := strings.ToLower(userAgent)
+	if i := strings.IndexAny(product, "/ "); i >= 0 {
+		product = product[:i]
+	}
+
+	var (
+		specific, wildcard robotsRules
+		hasSpecific        bool
+		current            []*robotsRules // groups the lines being read belong to
+		inAgents           bool           // reading the user-agent lines starting a group
+		sitemaps           []string
+	)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if !inAgents {
+				current = nil
+				inAgents = true
+			}
+			switch agent := strings.ToLower(value); {
+			case agent == "*":
+				current = append(current, &wildcard)
+			case agent == product:
+				hasSpecific = true
+				current = append(current, &specific)
+			}
+		case "allow", "disallow":
+			inAgents = false
+			if value == "" {
+				// an empty Disallow allows everything, which is the default
+				continue
+			}
+			for _, group := range current {
+				group.rules = append(group.rules, robotsRule{allow: key == "allow", pattern: value})
+			}
+		case "crawl-delay":
+			inAgents = false
+			secs, err := strconv.ParseFloat(value, 64)
+			if err != nil || secs <= 0 {
+				continue
+			}
+			for _, group := range current {
+				group.crawlDelay = time.Duration(secs * float64(time.Second))
+			}
+		case "sitemap":
+			sitemaps = append(sitemaps, value)
+		default:
+			inAgents = false
+		}
+	}
+
+	rules := &wildcard
+	if hasSpecific {
+		rules = &specific
+	}
+	rules.sitemaps = sitemaps
+	return rules
+}
+
+// matchRobotsPattern reports whether path matches a robots.txt path pattern.
+func matchRobotsPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = strings.TrimSuffix(pattern, "$")
+	}
+
+	parts := strings.Split(pattern, "*")
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	pos := len(parts[0])
+
+	for i, part := range parts[1:] {
+		if anchored && i == len(parts)-2 {
+			// the last part must match at the very end
+			return strings.HasSuffix(path[pos:], part)
+		}
+		idx := strings.Index(path[pos:], part)
+		if idx < 0 {
+			return false
+		}
+		pos += idx + len(part)
+	}
+	return !anchored || pos == len(path)
+}
@@ -0,0 +1,236 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// newRobotsSite serves robots.txt with the given status and body, and "ok" for every other path.
+// It counts the requests made for robots.txt.
+func newRobotsSite(t *testing.T, status int, robots string) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+
+	var fetches atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			fetches.Add(1)
+			w.WriteHeader(status)
+			w.Write([]byte(robots))
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &fetches
+}
+
+func TestRobots(t *testing.T) {
+	const robots = `
+User-agent: *
+Disallow: /private/
+Allow: /private/public$
+Disallow: /*.pdf$
+
+User-agent: other-bot
+Disallow: /
+
+User-agent: awesome-tools-scraper
+Disallow: /only-for-us/
+Disallow: /private/
+Allow: /private/public$
+Disallow: /*.pdf$
+`
+
+	tests := []struct {
+		name      string
+		status    int
+		robots    string
+		allowed   []string
+		disallows []string
+	}{
+		{
+			name:      "group for our user agent",
+			status:    http.StatusOK,
+			robots:    robots,
+			allowed:   []string{"/", "/private/public", "/docs/file.pdf?x=1"},
+			disallows: []string{"/only-for-us/page", "/private/secret", "/docs/file.pdf"},
+		},
+		{
+			name:      "wildcard group",
+			status:    http.StatusOK,
+			robots:    "User-agent: *\nDisallow: /admin\n",
+			allowed:   []string{"/", "/adm", "/docs/admin"},
+			disallows: []string{"/admin", "/admin/users"},
+		},
+		{
+			name:    "missing robots.txt allows everything",
+			status:  http.StatusNotFound,
+			allowed: []string{"/", "/private/secret"},
+		},
+		{
+			name:      "server error disallows everything",
+			status:    http.StatusServiceUnavailable,
+			disallows: []string{"/", "/docs"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, fetches := newRobotsSite(t, tt.status, tt.robots)
+
+			s := newTestScraper(t, 2)
+			s.SetRespectRobots(true)
+
+			var urls []string
+			for _, path := range append(tt.allowed, tt.disallows...) {
+				urls = append(urls, srv.URL+path)
+			}
+			results, err := s.ScrapeURLs(context.Background(), urls)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := byURL(collect(t, results))
+
+			for _, path := range tt.allowed {
+				if result := got[srv.URL+path]; result.Error != nil || result.Content != "ok" {
+					t.Errorf("%s: expected to be fetched, got %q and error %v", path, result.Content, result.Error)
+				}
+			}
+			for _, path := range tt.disallows {
+				if result := got[srv.URL+path]; !errors.Is(result.Error, scraper.ErrDisallowedByRobots) {
+					t.Errorf("%s: expected ErrDisallowedByRobots, got %v", path, result.Error)
+				}
+			}
+			if n := fetches.Load(); n != 1 {
+				t.Errorf("expected robots.txt to be fetched once, got %d", n)
+			}
+		})
+	}
+}
+
+func TestRobotsIgnored(t *testing.T) {
+	srv, fetches := newRobotsSite(t, http.StatusOK, "User-agent: *\nDisallow: /\n")
+
+	result := scrapeOne(t, newTestScraper(t, 1), srv.URL+"/page")
+	if result.Error != nil {
+		t.Errorf("expected robots.txt to be ignored, got %v", result.Error)
+	}
+	if n := fetches.Load(); n != 0 {
+		t.Errorf("expected robots.txt not to be fetched, got %d requests", n)
+	}
+}
+
+func TestRobotsCrawlDelay(t *testing.T) {
+	srv, _ := newRobotsSite(t, http.StatusOK, "User-agent: *\nCrawl-delay: 0.05\n")
+
+	s := newTestScraper(t, 3)
+	s.SetRespectRobots(true)
+
+	// the first request may go at once, the other two wait for the crawl delay in turn
+	start := time.Now()
+	results, err := s.ScrapeURLs(context.Background(), []string{srv.URL + "/1", srv.URL + "/2", srv.URL + "/3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, results)
+
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected the crawl delay to space out requests, took %s", elapsed)
+	}
+}
+
+func TestRobotsCustomUserAgent(t *testing.T) {
+	srv, _ := newRobotsSite(t, http.StatusOK, "User-agent: *\nAllow: /\n\nUser-agent: MyBot\nDisallow: /\n")
+
+	s := newTestScraper(t, 1)
+	s.SetRespectRobots(true)
+	s.SetUserAgent("MyBot/2.0 (+https://example.com/bot)")
+
+	result := scrapeOne(t, s, srv.URL+"/page")
+	if !errors.Is(result.Error, scraper.ErrDisallowedByRobots) {
+		t.Errorf("expected the group of the user agent's product to apply, got %v", result.Error)
+	}
+}
+
+// countingLimiter counts the calls to Wait of the limiter it wraps.
+type countingLimiter struct {
+	rlm.Limiter
+	waits atomic.Int32
+}
+
+func (cl *countingLimiter) Wait(ctx context.Context) bool {
+	cl.waits.Add(1)
+	return cl.Limiter.Wait(ctx)
+}
+
+func TestRobotsRateLimited(t *testing.T) {
+	srv, fetches := newRobotsSite(t, http.StatusOK, "User-agent: *\nAllow: /\n")
+
+	l := &countingLimiter{Limiter: rlm.NewTokenBucket(1000, time.Second, 1000)}
+	defer l.Stop()
+	s := newTestScraper(t, 1)
+	s.SetLimiter(l)
+	s.SetRespectRobots(true)
+
+	if result := scrapeOne(t, s, srv.URL+"/page"); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	// robots.txt is a request to the site like any other
+	if n := l.waits.Load(); n != 2 || fetches.Load() != 1 {
+		t.Errorf("expected robots.txt and the page to wait for the limiter, got %d waits", n)
+	}
+}
+
+func TestRobotsFetchCancelled(t *testing.T) {
+	var fetches atomic.Int32
+	hung := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/robots.txt" {
+			w.Write([]byte("ok"))
+			return
+		}
+		// the first fetch hangs until its caller gives up
+		if fetches.Add(1) == 1 {
+			close(hung)
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("User-agent: *\nAllow: /\n"))
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	s.SetRespectRobots(true)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first, err := s.ScrapeURLs(ctx, []string{srv.URL + "/first"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-hung
+	second, err := s.ScrapeURLs(context.Background(), []string{srv.URL + "/second"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	collect(t, first)
+
+	// the cancelled fetch is not taken as the site's answer, for the caller waiting on it or later ones
+	if got := collect(t, second); len(got) != 1 || got[0].Error != nil {
+		t.Errorf("expected robots.txt to be fetched again, got %+v", got)
+	}
+	if result := scrapeOne(t, s, srv.URL+"/third"); result.Error != nil {
+		t.Errorf("expected the rules fetched again to be cached, got %v", result.Error)
+	}
+	if n := fetches.Load(); n != 2 {
+		t.Errorf("expected robots.txt to be fetched again once, got %d fetches", n)
+	}
+}
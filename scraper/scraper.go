@@ -1,9 +1,14 @@
 package scraper
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"iter"
 	"net/http"
+	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -17,79 +22,313 @@ const (
 )
 
 type RateLimitedScraper struct {
-	client     *http.Client
-	rlm        *rlm.RateLimiter
-	maxWorkers int
-	scrapeFunc ScrapeFunc
+	client      *http.Client
+	limiter     rlm.Limiter
+	ownLimiter  bool // limiter was created by SetRateLimit, so the scraper stops it
+	hostLimits  *rlm.KeyedLimiter
+	maxWorkers  int
+	fetcher     Fetcher
+	routes      []Route
+	retry       RetryPolicy
+	maxBodySize int64
+	userAgent   string
+	robots      *robotsCache
+	extractors  []Extractor
+	cache       ResponseCache
+
+	requestTemplate RequestTemplate
+	hostTemplates   map[string]RequestTemplate
+	clients         clientPool
+	stats           scrapeStats
+	keepRawContent  bool
 }
 
 func NewScraper(rps int, maxWorkers int) *RateLimitedScraper {
 	s := &RateLimitedScraper{
-		client:     &http.Client{Timeout: ScrapeTimeoutDuration},
-		maxWorkers: maxWorkers,
+		client:      &http.Client{Timeout: ScrapeTimeoutDuration},
+		maxWorkers:  maxWorkers,
+		maxBodySize: DefaultMaxBodySize,
+		userAgent:   DefaultUserAgent,
 	}
+	s.robots = newRobotsCache(s.newGetRequest, s.wait, s.userAgent)
 
 	// Use default scrape function
-	s.scrapeFunc = s.scrapeURL
+	s.fetcher = ScrapeFunc(s.scrapeURL)
 	return s
 }
 
-// Sets the rate limit of the scraper.
+// Sets the rate limit of the scraper. The limiter created for it is stopped once a scrape is done.
 func (s *RateLimitedScraper) SetRateLimit(
 	ctx context.Context,
 	timeSpan time.Duration,
 	intervals int,
 ) {
-	r := rlm.NewRateLimiter(ctx, timeSpan, intervals)
-	s.rlm = &r
+	s.limiter = rlm.NewRateLimiter(ctx, timeSpan, intervals)
+	s.ownLimiter = true
+}
+
+// Sets the rate limiting strategy of the scraper, e.g. a fixed or sliding window
+// matching how the upstream server counts requests. The caller stops l when done with it,
+// so it can be shared between scrapes and scrapers.
+func (s *RateLimitedScraper) SetLimiter(l rlm.Limiter) {
+	s.limiter = l
+	s.ownLimiter = false
+}
+
+// Sets a limiter per host, so a slow or strict host does not use up the budget of the others.
+// It applies in addition to the limiter set with SetRateLimit or SetLimiter, if any.
+// The caller stops kl when done with it.
+func (s *RateLimitedScraper) SetHostLimiter(kl *rlm.KeyedLimiter) {
+	s.hostLimits = kl
+}
+
+// Sets the retry policy for failed scrapes, e.g. DefaultRetryPolicy.
+// Every attempt waits for the rate limiters again. By default each URL is attempted once.
+func (s *RateLimitedScraper) SetRetryPolicy(p RetryPolicy) {
+	s.retry = p
+}
+
+// Sets the largest decoded body the default scrape function keeps, in bytes.
+// Longer bodies are cut off and marked as Truncated. Defaults to DefaultMaxBodySize.
+// Returns an error, leaving the size unchanged, if n is not positive.
+func (s *RateLimitedScraper) SetMaxBodySize(n int64) error {
+	if n <= 0 {
+		return fmt.Errorf("max body size must be positive, got %d", n)
+	}
+	s.maxBodySize = n
+	return nil
+}
+
+// Sets whether the default scrape function keeps the body of every response as received, before
+// decompression and charset conversion, in Result.RawContent, e.g. for a WARCSink. Off by default,
+// as it holds the body twice. Results served from the cache, including those revalidated with a
+// 304, have no raw content, as the cache keeps the decoded content only.
+func (s *RateLimitedScraper) SetKeepRawContent(keep bool) {
+	s.keepRawContent = keep
+}
+
+// Sets the User-Agent header sent with every request, which also picks the robots.txt rules that apply.
+func (s *RateLimitedScraper) SetUserAgent(userAgent string) {
+	s.userAgent = userAgent
+	if s.robots != nil {
+		s.robots = newRobotsCache(s.newGetRequest, s.wait, userAgent)
+	}
+}
+
+// Sets whether robots.txt is honoured, which it is by default. When honoured, robots.txt is fetched
+// once per host, through the same limiters as the pages, URLs it disallows fail with
+// ErrDisallowedByRobots, and a Crawl-delay limits requests to that host in addition to the other limiters.
+func (s *RateLimitedScraper) SetRespectRobots(respect bool) {
+	if !respect {
+		s.robots = nil
+		return
+	}
+	if s.robots == nil {
+		s.robots = newRobotsCache(s.newGetRequest, s.wait, s.userAgent)
+	}
+}
+
+// Sets the extractors run on every HTML page scraped, e.g. MetaExtractor{} and MainTextExtractor{},
+// which attach structured data to the Result.
+func (s *RateLimitedScraper) SetExtractors(extractors ...Extractor) {
+	s.extractors = extractors
+}
+
+// Sets the cache the default scrape function keeps responses in, e.g. a DiskCache.
+// Responses still fresh by their Cache-Control or Expires headers are served without a request,
+// and stale ones are revalidated with If-None-Match and If-Modified-Since.
+func (s *RateLimitedScraper) SetCache(c ResponseCache) {
+	s.cache = c
+}
+
+// Sets the template of the requests sent by the default scrape function, e.g. to add headers,
+// authenticate, keep cookies or go through a proxy.
+func (s *RateLimitedScraper) SetRequestTemplate(t RequestTemplate) {
+	s.requestTemplate = t
+}
+
+// Sets the request template for a host and its subdomains, applied over the scraper's template.
+// The template of the most specific host wins, e.g. "api.example.com" over "example.com".
+func (s *RateLimitedScraper) SetHostTemplate(host string, t RequestTemplate) {
+	if s.hostTemplates == nil {
+		s.hostTemplates = make(map[string]RequestTemplate)
+	}
+	s.hostTemplates[strings.ToLower(host)] = t
 }
 
 // Allow callers to set a custom scrape function
 func (s *RateLimitedScraper) SetScrapeFunc(fn ScrapeFunc) {
-	s.scrapeFunc = fn
+	s.fetcher = fn
+}
+
+// Sets the fetcher used for URLs no route matches, which is plain HTTP by default.
+func (s *RateLimitedScraper) SetFetcher(f Fetcher) {
+	s.fetcher = f
+}
+
+// Sets the routes sending selected hosts or URL patterns to other fetchers, e.g. a RenderFetcher
+// for pages built by JavaScript. The first matching route wins.
+func (s *RateLimitedScraper) SetRoutes(routes ...Route) {
+	s.routes = routes
 }
 
 func (s *RateLimitedScraper) ScrapeURLs(
 	ctx context.Context,
 	urls []string) (<-chan Result, error) {
-	if s.rlm == nil {
+	return s.scrapeURLs(ctx, urls, nil)
+}
+
+// scrapeURLs scrapes urls, recording every result in state before sending it if the scrape is part of a job.
+func (s *RateLimitedScraper) scrapeURLs(
+	ctx context.Context,
+	urls []string,
+	state *jobState) (<-chan Result, error) {
+	if s.limiter == nil && s.hostLimits == nil {
 		return nil, fmt.Errorf("rate limiter not set")
 	}
 
 	results := make(chan Result, len(urls))
-	urlChan := make(chan string, len(urls))
+	jobs := make(chan job, len(urls))
+
+	found := results
+	if state != nil {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		found = make(chan Result, s.maxWorkers)
 
+		go func() {
+			defer close(results)
+			defer cancel()
+			for result := range found {
+				if !state.complete(ctx, result) {
+					return
+				}
+				results <- result
+			}
+		}()
+	}
+
+	s.stats.begin()
+	s.stats.queue(len(urls))
 	go func() {
-		defer close(urlChan)
-		for _, url := range urls {
+		defer close(jobs)
+		for i, url := range urls {
 			select {
-			case urlChan <- url:
+			case jobs <- job{url: url}:
 			case <-ctx.Done():
-				// s.rlm.Stop() is not required because the expectation is that the same ctx,
+				// s.limiter.Stop() is not required because the expectation is that the same ctx,
 				// would have been passed to the rate limiter while the latter's initialization.
+				s.stats.drop(len(urls) - i)
+				return
+			}
+		}
+	}()
+
+	s.startWorkers(ctx, jobs, found)
+	return results, nil
+}
+
+// ScrapeStream scrapes the URLs received from urls as they arrive, until urls is closed.
+// Unlike ScrapeURLs, buffers do not grow with the number of URLs: when results are not read,
+// the workers stop, and so does reading from urls. The results channel is closed once urls is
+// closed and every URL received is scraped.
+func (s *RateLimitedScraper) ScrapeStream(
+	ctx context.Context,
+	urls <-chan string) (<-chan Result, error) {
+	if s.limiter == nil && s.hostLimits == nil {
+		return nil, fmt.Errorf("rate limiter not set")
+	}
+
+	results := make(chan Result, s.maxWorkers)
+	jobs := make(chan job)
+
+	s.stats.begin()
+	go func() {
+		defer close(jobs)
+		for {
+			select {
+			case url, ok := <-urls:
+				if !ok {
+					return
+				}
+				s.stats.queue(1)
+				select {
+				case jobs <- job{url: url}:
+				case <-ctx.Done():
+					s.stats.drop(1)
+					return
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	s.startWorkers(ctx, jobs, results)
+	return results, nil
+}
+
+// ScrapeSeq scrapes the URLs of seq like ScrapeStream, e.g. lines read from a file
+// or rows from a database cursor. seq is iterated in its own goroutine, one URL
+// at a time as workers become free, and is stopped early if ctx is canceled.
+func (s *RateLimitedScraper) ScrapeSeq(
+	ctx context.Context,
+	seq iter.Seq[string]) (<-chan Result, error) {
+	urls := make(chan string)
+	results, err := s.ScrapeStream(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+
+	go func() {
+		defer close(urls)
+		for url := range seq {
+			select {
+			case urls <- url:
+			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
+	return results, nil
+}
+
+// job is a URL for a worker to scrape, found at the given crawl depth.
+type job struct {
+	url   string
+	depth int
+}
+
+// startWorkers starts the worker pool scraping jobs into results.
+// Once every worker is done, results is closed, the run begun by the caller ends and
+// the limiter created by SetRateLimit, if any, is stopped.
+func (s *RateLimitedScraper) startWorkers(ctx context.Context, jobs <-chan job, results chan<- Result) {
 	var wg sync.WaitGroup
 	for i := 0; i < s.maxWorkers; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for url := range urlChan {
+			for j := range jobs {
+				s.stats.start()
+				result, ok := s.scrape(ctx, j.url)
+				if !ok {
+					s.stats.abandon()
+					// s.limiter.Stop() is not required because the expectation is that the same ctx,
+					// would have been passed to the rate limiter while the latter's initialization.
+					return
+				}
+				if result.URL == "" {
+					result.URL = j.url
+				}
+				result.Depth = j.depth
+				s.extract(&result)
+				s.stats.finish(result)
+
 				select {
-				case <-s.rlm.LimitCh(ctx):
-					result := s.scrapeFunc(ctx, url)
-					select {
-					case results <- result:
-					case <-ctx.Done():
-						// s.rlm.Stop() is not required because the expectation is that the same ctx,
-						// would have been passed to the rate limiter while the latter's initialization.
-						return
-					}
+				case results <- result:
 				case <-ctx.Done():
-					// s.rlm.Stop() is not required because the expectation is that the same ctx,
+					// s.limiter.Stop() is not required because the expectation is that the same ctx,
 					// would have been passed to the rate limiter while the latter's initialization.
 					return
 				}
@@ -99,31 +338,220 @@ func (s *RateLimitedScraper) ScrapeURLs(
 
 	go func() {
 		wg.Wait()
+		// workers stop early when the context is done, leaving jobs no one takes
+		for range jobs {
+			s.stats.drop(1)
+		}
+		s.stats.end()
 		close(results)
-		s.rlm.Stop()
+		if s.ownLimiter {
+			s.limiter.Stop()
+		}
 	}()
+}
 
-	return results, nil
+// scrape fetches url, retrying according to the retry policy.
+// Returns false if the context is done before an attempt could be made. A limiter which gives up
+// for another reason, e.g. because it was stopped, fails the URL with its error instead.
+func (s *RateLimitedScraper) scrape(ctx context.Context, url string) (Result, bool) {
+	if result, ok := s.cached(url); ok {
+		return result, true
+	}
+
+	var crawlDelay rlm.Limiter
+	if s.robots != nil && !s.offline(url) {
+		allowed, limiter, err := s.robots.check(ctx, url)
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, false
+			}
+			return Result{URL: url, Error: err}, true
+		}
+		if !allowed {
+			return Result{URL: url, Error: ErrDisallowedByRobots}, true
+		}
+		crawlDelay = limiter
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := s.wait(ctx, url)
+		if err == nil && crawlDelay != nil && !crawlDelay.Wait(ctx) {
+			err = waitErr(ctx)
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return Result{}, false
+			}
+			return Result{URL: url, Attempts: attempt - 1, Error: err}, true
+		}
+
+		start := time.Now()
+		result := s.fetch(ctx, url)
+		s.stats.request(time.Since(start))
+		result.Attempts = attempt
+		if result.FetchedAt.IsZero() {
+			result.FetchedAt = start
+		}
+
+		delay, retry := s.retry.retryDelay(result, attempt)
+		if !retry || !sleep(ctx, delay) {
+			return result, true
+		}
+	}
+}
+
+// wait blocks until both the scraper-wide and the per-host limiters permit fetching rawURL.
+// Returns the context error, or rlm.ErrLimiterStopped if a limiter gave up for another reason.
+func (s *RateLimitedScraper) wait(ctx context.Context, rawURL string) error {
+	if s.limiter != nil && !s.limiter.Wait(ctx) {
+		return waitErr(ctx)
+	}
+	if s.hostLimits != nil && !s.hostLimits.Wait(ctx, hostOf(rawURL)) {
+		return waitErr(ctx)
+	}
+	return nil
+}
+
+// waitErr returns why a limiter's Wait gave up: the context error, or rlm.ErrLimiterStopped.
+func waitErr(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return rlm.ErrLimiterStopped
+}
+
+// hostOf returns the host of rawURL, used as the key for per-host limits.
+func hostOf(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return u.Host
 }
 
 type Result struct {
-	URL     string
-	Content string
-	Error   error
+	URL         string
+	FinalURL    string // URL after following redirects
+	Content     string // body, decoded, and converted to UTF-8 if it is text
+	RawContent  []byte // body as received, before decoding, if kept with SetKeepRawContent; not cached
+	Truncated   bool   // body was longer than the maximum body size and was cut off
+	StatusCode  int
+	Header      http.Header
+	Attempts    int               // number of attempts made, including retries
+	Depth       int               // number of links followed from a seed URL when crawling
+	Fields      map[string]string // set by SelectorExtractor
+	MainText    string            // set by MainTextExtractor
+	Meta        *PageMeta         // set by MetaExtractor
+	FromCache   bool              // served from the cache without a request, as it was still fresh
+	NotModified bool              // served from the cache after the server answered 304 Not Modified
+	FetchedAt   time.Time         // when the fetch of the final attempt started
+	Error       error
+	// ExtractError is set if parsing the page or an extractor failed, which leaves Error alone
+	// as the page itself was fetched.
+	ExtractError error
+
+	received int64 // bytes of the body read from the connection, counted by Stats
 }
 
 func (s *RateLimitedScraper) scrapeURL(ctx context.Context, url string) Result {
-	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	t := s.template(url)
+	req, err := t.newRequest(ctx, url, s.userAgent)
 	if err != nil {
 		return Result{URL: url, Error: err}
 	}
 
-	resp, err := s.client.Do(req)
+	client := s.clientFor(t)
+
+	// the response to anything but a plain GET depends on what was sent
+	cache := s.cache
+	if !t.plainGet() {
+		cache = nil
+	}
+
+	var cached *CachedResponse
+	if cache != nil {
+		cached, err = cache.Get(url)
+		if err != nil {
+			return Result{URL: url, Error: fmt.Errorf("reading cache: %w", err)}
+		}
+		if cached != nil && (!cached.matches(req) || !cached.revalidate(req)) {
+			cached = nil
+		}
+	}
+
+	resp, err := client.Do(req)
 	if err != nil {
 		return Result{URL: url, Error: err}
 	}
 	defer resp.Body.Close()
 
-	// Read content (simplified)
-	return Result{URL: url, Content: fmt.Sprintf("Status: %d", resp.StatusCode)}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		cached.notModified(resp, time.Now())
+		result := cached.result()
+		result.URL = url
+		result.NotModified = true
+		if err := cache.Put(url, cached); err != nil {
+			result.Error = fmt.Errorf("writing cache: %w", err)
+		}
+		return result
+	}
+
+	result := Result{
+		URL:        url,
+		FinalURL:   resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Header:     resp.Header,
+	}
+	var raw *bytes.Buffer
+	if s.keepRawContent {
+		raw = new(bytes.Buffer)
+		resp.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.TeeReader(resp.Body, raw), resp.Body}
+	}
+	result.Content, result.Truncated, result.received, result.Error = readBody(resp, s.maxBodySize)
+	if raw != nil {
+		// an empty body is still a body kept
+		result.RawContent = append([]byte{}, raw.Bytes()...)
+	}
+
+	if cache != nil && result.Error == nil && !result.Truncated && cacheable(resp) {
+		err := cache.Put(url, &CachedResponse{
+			URL:           url,
+			FinalURL:      result.FinalURL,
+			StatusCode:    result.StatusCode,
+			Header:        result.Header,
+			Content:       result.Content,
+			StoredAt:      time.Now(),
+			RequestHeader: requestVary(resp),
+		})
+		if err != nil {
+			result.Error = fmt.Errorf("writing cache: %w", err)
+		}
+	}
+	return result
+}
+
+// cached returns the cached response for url if it is still fresh, and fetched
+// with the same values of the headers it varies on as would be sent now.
+func (s *RateLimitedScraper) cached(url string) (Result, bool) {
+	t := s.template(url)
+	if s.cache == nil || !t.plainGet() {
+		return Result{}, false
+	}
+
+	cr, err := s.cache.Get(url)
+	if err != nil || cr == nil || !cr.fresh(time.Now()) {
+		// errors are reported by the request made instead
+		return Result{}, false
+	}
+	if req, err := t.newRequest(context.Background(), url, s.userAgent); err != nil || !cr.matches(req) {
+		return Result{}, false
+	}
+
+	result := cr.result()
+	result.URL = url
+	result.FromCache = true
+	return result, true
 }
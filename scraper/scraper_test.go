@@ -0,0 +1,249 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	rlm "github.com/hiteshrepo/awesome-tools/rate-limiter"
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// newTestScraper creates a scraper for tests which is not held back by rate limits or robots.txt.
+func newTestScraper(t *testing.T, workers int) *scraper.RateLimitedScraper {
+	t.Helper()
+
+	l := rlm.NewTokenBucket(1000, time.Second, 1000)
+	t.Cleanup(l.Stop)
+
+	s := scraper.NewScraper(1000, workers)
+	s.SetLimiter(l)
+	s.SetRespectRobots(false)
+	return s
+}
+
+// collect reads every result from results, failing the test if they do not arrive in time.
+func collect(t *testing.T, results <-chan scraper.Result) []scraper.Result {
+	t.Helper()
+
+	timeout := time.After(5 * time.Second)
+	var collected []scraper.Result
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				return collected
+			}
+			collected = append(collected, result)
+		case <-timeout:
+			t.Fatalf("timed out after %d results", len(collected))
+		}
+	}
+}
+
+// byURL indexes results by their URL.
+func byURL(results []scraper.Result) map[string]scraper.Result {
+	indexed := make(map[string]scraper.Result, len(results))
+	for _, result := range results {
+		indexed[result.URL] = result
+	}
+	return indexed
+}
+
+func TestScrapeURLs(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "page %s", r.URL.Path)
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 2)
+	urls := []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"}
+
+	results, err := s.ScrapeURLs(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := byURL(collect(t, results))
+	if len(got) != len(urls) {
+		t.Fatalf("expected %d results, got %d", len(urls), len(got))
+	}
+	for _, url := range urls {
+		result := got[url]
+		if result.Error != nil {
+			t.Errorf("%s: unexpected error %v", url, result.Error)
+		}
+		if want := "page " + url[len(srv.URL):]; result.Content != want {
+			t.Errorf("%s: expected content %q, got %q", url, want, result.Content)
+		}
+	}
+}
+
+func TestScrapeWithoutLimiter(t *testing.T) {
+	s := scraper.NewScraper(1, 1)
+	if _, err := s.ScrapeURLs(context.Background(), []string{"http://example.com"}); err == nil {
+		t.Error("expected an error without a rate limiter")
+	}
+}
+
+func TestScrapeReusesCallerLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	hosts := rlm.NewKeyedLimiter(func() rlm.Limiter { return rlm.NewTokenBucket(1000, time.Second, 1000) }, 0, 0)
+	defer hosts.Stop()
+	s.SetHostLimiter(hosts)
+
+	// the limiters given to the scraper belong to the caller, so a second scrape can use them
+	for run := 1; run <= 2; run++ {
+		results, err := s.ScrapeURLs(context.Background(), []string{srv.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got := collect(t, results)
+		if len(got) != 1 || got[0].Error != nil {
+			t.Fatalf("run %d: expected one successful result, got %+v", run, got)
+		}
+	}
+}
+
+func TestScrapeStoppedHostLimiter(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	hosts := rlm.NewKeyedLimiter(func() rlm.Limiter {
+		tb := rlm.NewTokenBucket(1, time.Second, 1)
+		tb.Stop()
+		return tb
+	}, 0, 0)
+	defer hosts.Stop()
+
+	s := newTestScraper(t, 1)
+	s.SetHostLimiter(hosts)
+
+	// a limiter which gives up fails the URL rather than dropping it
+	urls := []string{srv.URL + "/a", srv.URL + "/b"}
+	results, err := s.ScrapeURLs(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := collect(t, results)
+	if len(got) != len(urls) {
+		t.Fatalf("expected %d results, got %d", len(urls), len(got))
+	}
+	for _, result := range got {
+		if !errors.Is(result.Error, rlm.ErrLimiterStopped) {
+			t.Errorf("%s: expected ErrLimiterStopped, got %v", result.URL, result.Error)
+		}
+	}
+}
+
+func TestScrapeStream(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	urls := make(chan string)
+	results, err := newTestScraper(t, 2).ScrapeStream(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		defer close(urls)
+		for _, path := range []string{"/a", "/b", "/c"} {
+			urls <- srv.URL + path
+		}
+	}()
+
+	got := byURL(collect(t, results))
+	for _, path := range []string{"/a", "/b", "/c"} {
+		if result, ok := got[srv.URL+path]; !ok || result.Error != nil {
+			t.Errorf("%s: expected a successful result, got %+v", path, result)
+		}
+	}
+}
+
+func TestScrapeSeqBoundsBuffers(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	const total = 100
+	var taken atomic.Int32
+	seq := func(yield func(string) bool) {
+		for i := range total {
+			taken.Add(1)
+			if !yield(fmt.Sprintf("%s/%d", srv.URL, i)) {
+				return
+			}
+		}
+	}
+
+	results, err := newTestScraper(t, 2).ScrapeSeq(context.Background(), seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// with nobody reading results, the workers stop once its buffer is full, and so does iterating seq
+	deadline := time.Now().Add(5 * time.Second)
+	for len(results) < cap(results) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the results buffer to fill up, got %d results", len(results))
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := taken.Load(); n > 10 {
+		t.Errorf("expected only a few URLs to be taken while results are not read, got %d", n)
+	}
+
+	if got := collect(t, results); len(got) != total {
+		t.Errorf("expected %d results once read, got %d", total, len(got))
+	}
+}
+
+func TestScrapeSeqStopsOnCancel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	stopped := make(chan struct{})
+	seq := func(yield func(string) bool) {
+		defer close(stopped)
+		for {
+			if !yield(srv.URL) {
+				return
+			}
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	results, err := newTestScraper(t, 2).ScrapeSeq(ctx, seq)
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-results
+	cancel()
+
+	collect(t, results)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the sequence to be stopped")
+	}
+}
@@ -0,0 +1,389 @@
+package scraper
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Sink persists scrape results, e.g. to a file.
+type Sink interface {
+	Write(result Result) error
+	// Close flushes anything buffered. It does not close the writer the sink was created with.
+	Close() error
+}
+
+var (
+	_ Sink = (*JSONLSink)(nil)
+	_ Sink = (*CSVSink)(nil)
+	_ Sink = (*DirSink)(nil)
+	_ Sink = (*WARCSink)(nil)
+)
+
+// Drain writes every result received from results to all the sinks, until results is closed.
+// A failed write does not stop the others: every sink is given every result, and the errors
+// of all the writes which failed are returned together once results is drained. The sinks are
+// not closed.
+func Drain(results <-chan Result, sinks ...Sink) error {
+	var errs []error
+	for result := range results {
+		for _, sink := range sinks {
+			if err := sink.Write(result); err != nil {
+				errs = append(errs, fmt.Errorf("writing %s: %w", result.URL, err))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// JSONLSink writes every result as one line of JSON, as a FileStore records it: an object
+// whose "result" holds the fields of Result, with the errors as strings and without the raw
+// content. OpenFileStore reads the file back, e.g. with FileStore.Results.
+type JSONLSink struct {
+	mu sync.Mutex
+	w  *bufio.Writer
+}
+
+// NewJSONLSink creates a sink writing JSON Lines to w.
+func NewJSONLSink(w io.Writer) *JSONLSink {
+	return &JSONLSink{w: bufio.NewWriter(w)}
+}
+
+func (js *JSONLSink) Write(result Result) error {
+	line, err := json.Marshal(fileRecord{Result: newStoredResult(result)})
+	if err != nil {
+		return err
+	}
+
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	js.w.Write(line)
+	return js.w.WriteByte('\n')
+}
+
+func (js *JSONLSink) Close() error {
+	js.mu.Lock()
+	defer js.mu.Unlock()
+
+	return js.w.Flush()
+}
+
+// csvColumns are the columns every CSVSink writes, before those of the extracted fields.
+var csvColumns = []string{"url", "final_url", "status_code", "content_type", "content_length", "depth", "attempts", "title", "error"}
+
+// CSVSink writes one row per result, with a header row first. The page content is left out;
+// the fields extracted by a SelectorExtractor can be added as columns.
+type CSVSink struct {
+	mu          sync.Mutex
+	w           *csv.Writer
+	fields      []string
+	wroteHeader bool
+}
+
+// NewCSVSink creates a sink writing CSV to w, with a column for each of the named Result.Fields.
+func NewCSVSink(w io.Writer, fields ...string) *CSVSink {
+	return &CSVSink{
+		w:      csv.NewWriter(w),
+		fields: fields,
+	}
+}
+
+func (cs *CSVSink) Write(result Result) error {
+	var title, errMsg string
+	if result.Meta != nil {
+		title = result.Meta.Title
+	}
+	if result.Error != nil {
+		errMsg = result.Error.Error()
+	}
+
+	row := []string{
+		result.URL,
+		result.FinalURL,
+		strconv.Itoa(result.StatusCode),
+		result.Header.Get("Content-Type"),
+		strconv.Itoa(len(result.Content)),
+		strconv.Itoa(result.Depth),
+		strconv.Itoa(result.Attempts),
+		title,
+		errMsg,
+	}
+	for _, field := range cs.fields {
+		row = append(row, result.Fields[field])
+	}
+
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	if !cs.wroteHeader {
+		if err := cs.w.Write(append(append([]string{}, csvColumns...), cs.fields...)); err != nil {
+			return err
+		}
+		cs.wroteHeader = true
+	}
+	return cs.w.Write(row)
+}
+
+func (cs *CSVSink) Close() error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+
+	cs.w.Flush()
+	return cs.w.Error()
+}
+
+// DirSink mirrors the content of pages into a directory, in the layout FileFetcher reads back:
+// <dir>/<host>/<path>, with the host directory named after the lowercased host name and port.
+// Paths ending in a slash or without a file extension are written to index.html in a directory
+// of that name, so that /docs and /docs/intro can both be kept. URLs which differ only in their
+// query overwrite each other. Failed results and statuses other than 200 are skipped.
+//
+// Files hold Result.Content: the body with its Content-Encoding undone, byte for byte for binary
+// types such as images, and converted to UTF-8 for text, which is the charset FileFetcher gives
+// HTML files. RawContent is not used, as it may still be compressed.
+type DirSink struct {
+	dir string
+}
+
+// NewDirSink creates a sink mirroring pages into dir.
+func NewDirSink(dir string) *DirSink {
+	return &DirSink{dir: dir}
+}
+
+func (ds *DirSink) Write(result Result) error {
+	if result.Error != nil || result.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	u, err := url.Parse(result.URL)
+	if err != nil {
+		return err
+	}
+
+	p := u.Path
+	if p != "" && !strings.HasSuffix(p, "/") && path.Ext(p) == "" {
+		p += "/"
+	}
+	name, err := mirrorFile(ds.dir, u, p)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(name, []byte(result.Content), 0o644)
+}
+
+func (ds *DirSink) Close() error {
+	return nil
+}
+
+// WARCSink writes results to a WARC 1.1 archive, the format web archives use, as one
+// record per result, after a warcinfo record describing the archive. Records are dated by
+// Result.FetchedAt and target Result.FinalURL.
+//
+// Results with a RawContent, kept by a scraper with SetKeepRawContent, are written as response
+// records holding the status, the headers and the body as the server sent them; only the chunking
+// undone by the HTTP client is missing, and Content-Length gives the length of the body. Other
+// results are written as resource records of their decoded content, with the charset of their
+// Content-Type set to UTF-8. A body cut off by the maximum body size is marked with WARC-Truncated.
+//
+// Results served from the cache are written as revisit records, which hold the headers of the
+// response but no body. Those answered 304 Not Modified use the server-not-modified profile, with
+// the status line of the 304; those still fresh use the identical-payload-digest profile, with the
+// digest of their decoded content, which is the payload of a resource record. Results without a
+// response are skipped.
+type WARCSink struct {
+	mu        sync.Mutex
+	w         io.Writer
+	compress  bool
+	wroteInfo bool
+}
+
+// NewWARCSink creates a sink writing a WARC archive to w. If compress is set, every record
+// is gzipped separately, as in a .warc.gz file.
+func NewWARCSink(w io.Writer, compress bool) *WARCSink {
+	return &WARCSink{w: w, compress: compress}
+}
+
+func (ws *WARCSink) Write(result Result) error {
+	if result.Error != nil || result.StatusCode == 0 {
+		return nil
+	}
+
+	target := result.FinalURL
+	if target == "" {
+		target = result.URL
+	}
+	date := result.FetchedAt
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var fields [][2]string
+	var block []byte
+	switch {
+	case result.NotModified:
+		block = httpMessage(http.StatusNotModified, result.Header, nil)
+		fields = [][2]string{
+			{"WARC-Type", "revisit"},
+			{"WARC-Target-URI", target},
+			{"WARC-Profile", "http://netpreserve.org/warc/1.1/revisit/server-not-modified"},
+			{"WARC-Refers-To-Target-URI", target},
+			{"Content-Type", "application/http;msgtype=response"},
+		}
+	case result.FromCache:
+		block = httpMessage(result.StatusCode, result.Header, nil)
+		fields = [][2]string{
+			{"WARC-Type", "revisit"},
+			{"WARC-Target-URI", target},
+			{"WARC-Profile", "http://netpreserve.org/warc/1.1/revisit/identical-payload-digest"},
+			{"WARC-Refers-To-Target-URI", target},
+			{"Content-Type", "application/http;msgtype=response"},
+			{"WARC-Payload-Digest", warcDigest([]byte(result.Content))},
+		}
+	case result.RawContent != nil:
+		block = httpMessage(result.StatusCode, result.Header, result.RawContent)
+		fields = [][2]string{
+			{"WARC-Type", "response"},
+			{"WARC-Target-URI", target},
+			{"Content-Type", "application/http;msgtype=response"},
+			{"WARC-Payload-Digest", warcDigest(result.RawContent)},
+		}
+	default:
+		block = []byte(result.Content)
+		fields = [][2]string{
+			{"WARC-Type", "resource"},
+			{"WARC-Target-URI", target},
+		}
+		if contentType := utf8ContentType(result.Header.Get("Content-Type")); contentType != "" {
+			fields = append(fields, [2]string{"Content-Type", contentType})
+		}
+	}
+	if result.Truncated {
+		fields = append(fields, [2]string{"WARC-Truncated", "length"})
+	}
+
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	if !ws.wroteInfo {
+		info := "software: " + DefaultUserAgent + "\r\nformat: WARC File Format 1.1\r\n"
+		err := ws.writeRecord(time.Now(), [][2]string{
+			{"WARC-Type", "warcinfo"},
+			{"Content-Type", "application/warc-fields"},
+		}, []byte(info))
+		if err != nil {
+			return err
+		}
+		ws.wroteInfo = true
+	}
+	return ws.writeRecord(date, fields, block)
+}
+
+func (ws *WARCSink) Close() error {
+	return nil
+}
+
+// writeRecord writes one WARC record dated date, adding the headers every record has. Must be called with mu held.
+func (ws *WARCSink) writeRecord(date time.Time, fields [][2]string, block []byte) error {
+	id, err := newRecordID()
+	if err != nil {
+		return err
+	}
+
+	var rec bytes.Buffer
+	rec.WriteString("WARC/1.1\r\n")
+	fmt.Fprintf(&rec, "WARC-Record-ID: %s\r\n", id)
+	fmt.Fprintf(&rec, "WARC-Date: %s\r\n", date.UTC().Format(time.RFC3339))
+	for _, f := range fields {
+		fmt.Fprintf(&rec, "%s: %s\r\n", f[0], f[1])
+	}
+	fmt.Fprintf(&rec, "WARC-Block-Digest: %s\r\n", warcDigest(block))
+	fmt.Fprintf(&rec, "Content-Length: %d\r\n", len(block))
+	rec.WriteString("\r\n")
+	rec.Write(block)
+	rec.WriteString("\r\n\r\n")
+
+	if !ws.compress {
+		_, err := ws.w.Write(rec.Bytes())
+		return err
+	}
+
+	gz := gzip.NewWriter(ws.w)
+	if _, err := gz.Write(rec.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// httpMessage returns an HTTP response with the given status, header and body, as a response or
+// revisit record holds it. The Content-Length is set to that of body, as the chunking undone by
+// the HTTP client is missing; a message without a body, as in a revisit record, keeps the header as is.
+func httpMessage(statusCode int, header http.Header, body []byte) []byte {
+	header = header.Clone()
+	if header == nil {
+		header = http.Header{}
+	}
+	if body != nil {
+		header.Del("Transfer-Encoding")
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "HTTP/1.1 %d %s\r\n", statusCode, http.StatusText(statusCode))
+	header.Write(&msg)
+	msg.WriteString("\r\n")
+	msg.Write(body)
+	return msg.Bytes()
+}
+
+// utf8ContentType returns contentType with its charset, if it names one, replaced by UTF-8,
+// which the content of a Result is converted to.
+func utf8ContentType(contentType string) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return contentType
+	}
+	if _, ok := params["charset"]; ok {
+		params["charset"] = "utf-8"
+	}
+	return mime.FormatMediaType(mediaType, params)
+}
+
+// warcDigest returns the SHA-1 digest of data in the form WARC tools expect.
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+// newRecordID returns a random UUID URN identifying a WARC record.
+func newRecordID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40 // version 4
+	b[8] = b[8]&0x3f | 0x80 // RFC 4122 variant
+	return fmt.Sprintf("<urn:uuid:%x-%x-%x-%x-%x>", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
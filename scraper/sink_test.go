@@ -0,0 +1,439 @@
+package scraper_test
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// failingSink fails to write the results whose URL it names.
+type failingSink struct {
+	fail    map[string]bool
+	written []string
+}
+
+func (fs *failingSink) Write(result scraper.Result) error {
+	if fs.fail[result.URL] {
+		return errors.New("disk full")
+	}
+	fs.written = append(fs.written, result.URL)
+	return nil
+}
+
+func (fs *failingSink) Close() error {
+	return nil
+}
+
+func TestDrain(t *testing.T) {
+	results := make(chan scraper.Result, 3)
+	for _, url := range []string{"/a", "/b", "/c"} {
+		results <- scraper.Result{URL: url}
+	}
+	close(results)
+
+	failing := &failingSink{fail: map[string]bool{"/a": true, "/c": true}}
+	healthy := &failingSink{}
+	err := scraper.Drain(results, failing, healthy)
+
+	// a failed write does not stop the sink from getting the next results
+	if !slices.Equal(failing.written, []string{"/b"}) || !slices.Equal(healthy.written, []string{"/a", "/b", "/c"}) {
+		t.Errorf("expected every result written to every sink, got %v and %v", failing.written, healthy.written)
+	}
+	if err == nil || !strings.Contains(err.Error(), "writing /a") || !strings.Contains(err.Error(), "writing /c") {
+		t.Errorf("expected the errors of both failed writes, got %v", err)
+	}
+}
+
+func TestJSONLSinkReadByFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.jsonl")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fetchedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	written := []scraper.Result{
+		{URL: "https://example.com/", StatusCode: http.StatusOK, Content: "home", FetchedAt: fetchedAt},
+		{URL: "https://example.com/down", Attempts: 3, Error: errors.New("connection refused")},
+	}
+	sink := scraper.NewJSONLSink(f)
+	for _, result := range written {
+		if err := sink.Write(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	results := storedResults(t, openStore(t, path))
+	if len(results) != len(written) {
+		t.Fatalf("expected %d results, got %d", len(written), len(results))
+	}
+	if results[0].Content != "home" || !results[0].FetchedAt.Equal(fetchedAt) {
+		t.Errorf("expected %+v, got %+v", written[0], results[0])
+	}
+	if results[1].Error == nil || results[1].Error.Error() != "connection refused" || results[1].Attempts != 3 {
+		t.Errorf("expected %+v, got %+v", written[1], results[1])
+	}
+}
+
+func TestCSVSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := scraper.NewCSVSink(&buf, "price")
+
+	results := []scraper.Result{
+		{
+			URL:        "https://example.com/p/1",
+			FinalURL:   "https://example.com/products/1",
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/html"}},
+			Content:    "<p>widget</p>",
+			Attempts:   1,
+			Depth:      2,
+			Fields:     map[string]string{"price": "9.99"},
+			Meta:       &scraper.PageMeta{Title: "Widget, blue"},
+		},
+		{URL: "https://example.com/p/2", Attempts: 3, Error: errors.New("timeout")},
+	}
+	for _, result := range results {
+		if err := sink.Write(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := [][]string{
+		{"url", "final_url", "status_code", "content_type", "content_length", "depth", "attempts", "title", "error", "price"},
+		{"https://example.com/p/1", "https://example.com/products/1", "200", "text/html", "13", "2", "1", "Widget, blue", "", "9.99"},
+		{"https://example.com/p/2", "", "0", "", "0", "0", "3", "", "timeout", ""},
+	}
+	if len(rows) != len(expect) {
+		t.Fatalf("expected %d rows, got %v", len(expect), rows)
+	}
+	for i := range expect {
+		if !slices.Equal(rows[i], expect[i]) {
+			t.Errorf("row %d: expected %v, got %v", i, expect[i], rows[i])
+		}
+	}
+}
+
+func TestDirSink(t *testing.T) {
+	dir := t.TempDir()
+	sink := scraper.NewDirSink(dir)
+
+	pages := map[string]string{
+		"https://Example.com/":             "home",
+		"https://example.com/docs":         "docs",
+		"https://example.com/docs/intro":   "intro",
+		"https://example.com/style.css":    "body {}",
+		"http://example.com:8080/admin/":   "admin",
+		"https://example.com/../../escape": "escape",
+	}
+	for url, content := range pages {
+		if err := sink.Write(scraper.Result{URL: url, StatusCode: http.StatusOK, Content: content}); err != nil {
+			t.Fatalf("%s: unexpected error %v", url, err)
+		}
+	}
+	skipped := []scraper.Result{
+		{URL: "https://example.com/missing", StatusCode: http.StatusNotFound, Content: "not found"},
+		{URL: "https://example.com/failed", Error: errors.New("timeout")},
+	}
+	for _, result := range skipped {
+		if err := sink.Write(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.Write(scraper.Result{URL: "http://../etc/passwd", StatusCode: http.StatusOK}); err == nil {
+		t.Error("expected an error for a host which is not a directory name")
+	}
+
+	// the mirror is read back by a FileFetcher
+	ff := scraper.FileFetcher{Dir: dir}
+	for url, content := range pages {
+		if result := ff.Fetch(context.Background(), url); result.Content != content {
+			t.Errorf("%s: expected %q read back, got %d %q", url, content, result.StatusCode, result.Content)
+		}
+	}
+	for _, result := range skipped {
+		if got := ff.Fetch(context.Background(), result.URL); got.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected nothing written, got %q", result.URL, got.Content)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "example.com_8080", "admin", "index.html")); err != nil {
+		t.Errorf("expected the port in the host directory: %v", err)
+	}
+	if entries, _ := filepath.Glob(filepath.Join(filepath.Dir(dir), "escape*")); len(entries) > 0 {
+		t.Errorf("expected nothing written outside the mirror, got %v", entries)
+	}
+}
+
+func TestDirSinkBinary(t *testing.T) {
+	// a PNG signature followed by bytes which are not valid UTF-8
+	const png = "\x89PNG\r\n\x1a\n\x00\xff\xe9\x80caf\xe9"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(encode(t, "gzip", []byte(png)))
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	s.SetKeepRawContent(true)
+	result := scrapeOne(t, s, srv.URL+"/logo.png")
+	if result.Error != nil {
+		t.Fatal(result.Error)
+	}
+
+	dir := t.TempDir()
+	if err := scraper.NewDirSink(dir).Write(result); err != nil {
+		t.Fatal(err)
+	}
+	got := scraper.FileFetcher{Dir: dir}.Fetch(context.Background(), result.URL)
+	if got.Content != png {
+		t.Errorf("expected the image read back byte for byte, got %q", got.Content)
+	}
+	if contentType := got.Header.Get("Content-Type"); contentType != "image/png" {
+		t.Errorf("expected an image/png file, got %q", contentType)
+	}
+}
+
+// warcRecord is a record read back from a WARC file.
+type warcRecord struct {
+	header textproto.MIMEHeader
+	block  []byte
+}
+
+// readWARC reads every record of a WARC file.
+func readWARC(t *testing.T, r io.Reader) []warcRecord {
+	t.Helper()
+
+	br := bufio.NewReader(r)
+	var records []warcRecord
+	for {
+		version, err := br.ReadString('\n')
+		if err == io.EOF {
+			return records
+		}
+		if err != nil || version != "WARC/1.1\r\n" {
+			t.Fatalf("expected a WARC/1.1 record, got %q and %v", version, err)
+		}
+
+		header, err := textproto.NewReader(br).ReadMIMEHeader()
+		if err != nil {
+			t.Fatal(err)
+		}
+		length, err := strconv.Atoi(header.Get("Content-Length"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		block := make([]byte, length)
+		if _, err := io.ReadFull(br, block); err != nil {
+			t.Fatal(err)
+		}
+		end := make([]byte, 4)
+		if _, err := io.ReadFull(br, end); err != nil || string(end) != "\r\n\r\n" {
+			t.Fatalf("expected a record to end with two CRLFs, got %q", end)
+		}
+		records = append(records, warcRecord{header: header, block: block})
+	}
+}
+
+func warcDigest(data []byte) string {
+	sum := sha1.Sum(data)
+	return "sha1:" + base32.StdEncoding.EncodeToString(sum[:])
+}
+
+func TestWARCSink(t *testing.T) {
+	page := "<html><body>caf\xe9</body></html>" // Latin-1
+	gzipped := encode(t, "gzip", []byte(page))
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, "/new", http.StatusMovedPermanently)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=iso-8859-1")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped)
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		keepRaw  bool
+		compress bool
+		check    func(t *testing.T, rec warcRecord)
+	}{
+		{
+			name:    "response records with the raw body",
+			keepRaw: true,
+			check: func(t *testing.T, rec warcRecord) {
+				if rec.header.Get("WARC-Type") != "response" {
+					t.Errorf("expected a response record, got %q", rec.header.Get("WARC-Type"))
+				}
+				if digest := rec.header.Get("WARC-Payload-Digest"); digest != warcDigest(gzipped) {
+					t.Errorf("expected the payload digest of the body sent, got %s", digest)
+				}
+				resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.block)), nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				if !bytes.Equal(body, gzipped) || resp.Header.Get("Content-Encoding") != "gzip" {
+					t.Errorf("expected the gzipped body as sent, got %q with Content-Encoding %q", body, resp.Header.Get("Content-Encoding"))
+				}
+				if resp.Header.Get("Content-Type") != "text/html; charset=iso-8859-1" {
+					t.Errorf("expected the original Content-Type, got %q", resp.Header.Get("Content-Type"))
+				}
+			},
+		},
+		{
+			name:     "resource records with the decoded content",
+			compress: true,
+			check: func(t *testing.T, rec warcRecord) {
+				if rec.header.Get("WARC-Type") != "resource" {
+					t.Errorf("expected a resource record, got %q", rec.header.Get("WARC-Type"))
+				}
+				if string(rec.block) != "<html><body>café</body></html>" {
+					t.Errorf("expected the decoded content, got %q", rec.block)
+				}
+				if contentType := rec.header.Get("Content-Type"); contentType != "text/html; charset=utf-8" {
+					t.Errorf("expected the UTF-8 charset, got %q", contentType)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestScraper(t, 1)
+			s.SetKeepRawContent(tt.keepRaw)
+			result := scrapeOne(t, s, srv.URL+"/old")
+			if result.Error != nil {
+				t.Fatal(result.Error)
+			}
+
+			var buf bytes.Buffer
+			sink := scraper.NewWARCSink(&buf, tt.compress)
+			if err := sink.Write(result); err != nil {
+				t.Fatal(err)
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			var r io.Reader = &buf
+			if tt.compress {
+				gz, err := gzip.NewReader(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				r = gz
+			}
+			records := readWARC(t, r)
+			if len(records) != 2 || records[0].header.Get("WARC-Type") != "warcinfo" {
+				t.Fatalf("expected a warcinfo record and one for the result, got %d records", len(records))
+			}
+
+			rec := records[1]
+			if target := rec.header.Get("WARC-Target-URI"); target != srv.URL+"/new" {
+				t.Errorf("expected the final URL as the target, got %q", target)
+			}
+			if date := rec.header.Get("WARC-Date"); date != result.FetchedAt.UTC().Format(time.RFC3339) {
+				t.Errorf("expected the fetch time %s, got %s", result.FetchedAt, date)
+			}
+			if digest := rec.header.Get("WARC-Block-Digest"); digest != warcDigest(rec.block) {
+				t.Errorf("expected the block digest to match, got %s", digest)
+			}
+			tt.check(t, rec)
+		})
+	}
+}
+
+func TestWARCSinkRevisits(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fresh":
+			w.Header().Set("Cache-Control", "max-age=60")
+		case "/etag":
+			w.Header().Set("ETag", `"v1"`)
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+		w.Write([]byte("page"))
+	}))
+	defer srv.Close()
+
+	s := newCachedScraper(t)
+	var buf bytes.Buffer
+	sink := scraper.NewWARCSink(&buf, false)
+	for _, path := range []string{"/fresh", "/etag", "/fresh", "/etag"} {
+		result := scrapeOne(t, s, srv.URL+path)
+		if result.Error != nil {
+			t.Fatal(result.Error)
+		}
+		if err := sink.Write(result); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records := readWARC(t, &buf)
+	if len(records) != 5 {
+		t.Fatalf("expected a warcinfo record and one per result, got %d records", len(records))
+	}
+	expect := []struct {
+		profile    string
+		statusCode int
+	}{
+		{"http://netpreserve.org/warc/1.1/revisit/identical-payload-digest", http.StatusOK},
+		{"http://netpreserve.org/warc/1.1/revisit/server-not-modified", http.StatusNotModified},
+	}
+	for i, want := range expect {
+		rec := records[3+i]
+		if rec.header.Get("WARC-Type") != "revisit" || rec.header.Get("WARC-Profile") != want.profile {
+			t.Errorf("record %d: expected a revisit with profile %s, got %q with %q", 3+i, want.profile, rec.header.Get("WARC-Type"), rec.header.Get("WARC-Profile"))
+		}
+		if uri := rec.header.Get("WARC-Refers-To-Target-URI"); uri != records[1+i].header.Get("WARC-Target-URI") {
+			t.Errorf("record %d: expected to refer to the first fetch, got %q", 3+i, uri)
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(rec.block)), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := io.ReadAll(resp.Body); resp.StatusCode != want.statusCode || len(body) != 0 {
+			t.Errorf("record %d: expected a %d without a body, got %d with %q", 3+i, want.statusCode, resp.StatusCode, body)
+		}
+	}
+	// the identical payload is the one of the resource record written first
+	if digest := records[3].header.Get("WARC-Payload-Digest"); digest != warcDigest(records[1].block) {
+		t.Errorf("expected the payload digest of the first fetch, got %s", digest)
+	}
+}
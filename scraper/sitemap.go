@@ -0,0 +1,340 @@
+package scraper
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// maxSitemapSize is the largest sitemap or feed read, after decompression,
+	// which is the limit set by the sitemaps protocol.
+	maxSitemapSize = 50 << 20
+	// maxSitemapDepth is how deep sitemap indexes are followed. The protocol does not allow
+	// nesting them, so this only guards against sites which do it anyway.
+	maxSitemapDepth = 3
+)
+
+// SeedURL is a URL found in a sitemap or feed, with the time it last changed if the source gives it.
+type SeedURL struct {
+	URL     string
+	LastMod time.Time
+}
+
+// SeedOptions filters the URLs found in sitemaps and feeds.
+type SeedOptions struct {
+	// Since, if set, leaves out URLs whose last change is known to be before it.
+	// URLs without a date are kept, as they may have changed.
+	Since time.Time
+}
+
+// keep reports whether a URL last changed at lastMod passes the filter.
+func (o SeedOptions) keep(lastMod time.Time) bool {
+	return o.Since.IsZero() || lastMod.IsZero() || !lastMod.Before(o.Since)
+}
+
+// SeedList returns the URLs of seeds, e.g. to pass to ScrapeURLs.
+func SeedList(seeds []SeedURL) []string {
+	urls := make([]string, len(seeds))
+	for i, seed := range seeds {
+		urls[i] = seed.URL
+	}
+	return urls
+}
+
+// SiteSeeds returns the URLs listed in the sitemaps of the site at siteURL: those named
+// by its robots.txt, or /sitemap.xml if there are none. If some sitemaps cannot be read,
+// the URLs of the others are returned along with an error.
+func (s *RateLimitedScraper) SiteSeeds(ctx context.Context, siteURL string, opts SeedOptions) ([]SeedURL, error) {
+	sitemaps, err := s.DiscoverSitemaps(ctx, siteURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []SeedURL
+	var errs []error
+	for _, sitemap := range sitemaps {
+		found, err := s.Sitemap(ctx, sitemap, opts)
+		seeds = append(seeds, found...)
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return dedupeSeeds(seeds), errors.Join(errs...)
+}
+
+// DiscoverSitemaps returns the sitemaps the robots.txt of the site at siteURL lists,
+// or the conventional /sitemap.xml if it lists none.
+func (s *RateLimitedScraper) DiscoverSitemaps(ctx context.Context, siteURL string) ([]string, error) {
+	u, err := url.Parse(siteURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q in %s", u.Scheme, siteURL)
+	}
+
+	robots := s.robots
+	if robots == nil {
+		robots = newRobotsCache(s.newGetRequest, s.wait, s.userAgent)
+	}
+	entry, err := robots.entry(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	if sitemaps := entry.rules.sitemaps; len(sitemaps) > 0 {
+		return sitemaps, nil
+	}
+	return []string{u.Scheme + "://" + u.Host + "/sitemap.xml"}, nil
+}
+
+// Sitemap returns the URLs listed in the sitemap at sitemapURL. Sitemap indexes are followed,
+// skipping the sitemaps they date before opts.Since, and gzipped sitemaps are decompressed.
+// If some sitemaps of an index cannot be read, the URLs of the others are returned along with an error.
+func (s *RateLimitedScraper) Sitemap(ctx context.Context, sitemapURL string, opts SeedOptions) ([]SeedURL, error) {
+	var seeds []SeedURL
+	var errs []error
+	seen := make(map[string]bool)
+
+	var read func(sitemapURL string, depth int)
+	read = func(sitemapURL string, depth int) {
+		if seen[sitemapURL] {
+			return
+		}
+		seen[sitemapURL] = true
+
+		var doc struct {
+			URLs     []sitemapEntry `xml:"url"`
+			Sitemaps []sitemapEntry `xml:"sitemap"`
+		}
+		if err := s.fetchXML(ctx, sitemapURL, &doc); err != nil {
+			errs = append(errs, fmt.Errorf("sitemap %s: %w", sitemapURL, err))
+			return
+		}
+
+		for _, entry := range doc.URLs {
+			lastMod := parseW3CDate(entry.LastMod)
+			if loc := strings.TrimSpace(entry.Loc); loc != "" && opts.keep(lastMod) {
+				seeds = append(seeds, SeedURL{URL: loc, LastMod: lastMod})
+			}
+		}
+
+		if depth >= maxSitemapDepth {
+			return
+		}
+		for _, entry := range doc.Sitemaps {
+			if loc := strings.TrimSpace(entry.Loc); loc != "" && opts.keep(parseW3CDate(entry.LastMod)) {
+				read(loc, depth+1)
+			}
+		}
+	}
+	read(sitemapURL, 0)
+
+	return dedupeSeeds(seeds), errors.Join(errs...)
+}
+
+type sitemapEntry struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod"`
+}
+
+// Feed returns the links of the items of the RSS or Atom feed at feedURL,
+// dated by when they were last updated or, failing that, published.
+func (s *RateLimitedScraper) Feed(ctx context.Context, feedURL string, opts SeedOptions) ([]SeedURL, error) {
+	var doc struct {
+		Channel struct {
+			Items []rssItem `xml:"item"`
+		} `xml:"channel"`
+		Items   []rssItem   `xml:"item"` // RSS 1.0 puts the items next to the channel
+		Entries []atomEntry `xml:"entry"`
+	}
+	if err := s.fetchXML(ctx, feedURL, &doc); err != nil {
+		return nil, fmt.Errorf("feed %s: %w", feedURL, err)
+	}
+
+	base, err := url.Parse(feedURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var seeds []SeedURL
+	add := func(link string, lastMod time.Time) {
+		ref, err := url.Parse(strings.TrimSpace(link))
+		if err != nil || link == "" || !opts.keep(lastMod) {
+			return
+		}
+		seeds = append(seeds, SeedURL{URL: base.ResolveReference(ref).String(), LastMod: lastMod})
+	}
+
+	for _, item := range append(doc.Channel.Items, doc.Items...) {
+		link := item.Link
+		if link == "" && item.GUID.IsPermaLink != "false" {
+			link = item.GUID.Value
+		}
+		lastMod := parseW3CDate(item.Date)
+		if lastMod.IsZero() {
+			lastMod = parseRSSDate(item.PubDate)
+		}
+		add(link, lastMod)
+	}
+
+	for _, entry := range doc.Entries {
+		lastMod := parseW3CDate(entry.Updated)
+		if lastMod.IsZero() {
+			lastMod = parseW3CDate(entry.Published)
+		}
+		add(entry.link(), lastMod)
+	}
+
+	return dedupeSeeds(seeds), nil
+}
+
+type rssItem struct {
+	Link string `xml:"link"`
+	GUID struct {
+		Value       string `xml:",chardata"`
+		IsPermaLink string `xml:"isPermaLink,attr"`
+	} `xml:"guid"`
+	PubDate string `xml:"pubDate"`
+	Date    string `xml:"date"` // dc:date
+}
+
+type atomEntry struct {
+	Links []struct {
+		Href string `xml:"href,attr"`
+		Rel  string `xml:"rel,attr"`
+	} `xml:"link"`
+	Updated   string `xml:"updated"`
+	Published string `xml:"published"`
+}
+
+// link returns the URL of the page an Atom entry is about.
+func (e atomEntry) link() string {
+	for _, l := range e.Links {
+		if l.Rel == "" || l.Rel == "alternate" {
+			return l.Href
+		}
+	}
+	return ""
+}
+
+// fetchXML fetches the XML document at rawURL into v, waiting for the scraper's limiters
+// and decompressing it if it is gzipped.
+func (s *RateLimitedScraper) fetchXML(ctx context.Context, rawURL string, v any) error {
+	if err := s.wait(ctx, rawURL); err != nil {
+		return err
+	}
+
+	req, client, err := s.newGetRequest(ctx, rawURL)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	body, err := decompress(resp.Body, resp.Header.Get("Content-Encoding"))
+	if err != nil {
+		return err
+	}
+
+	// .xml.gz files are served gzipped as they are, rather than with a Content-Encoding
+	br := bufio.NewReader(body)
+	if magic, _ := br.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(br)
+		if err != nil {
+			return fmt.Errorf("decoding gzip: %w", err)
+		}
+		body = gz
+	} else {
+		body = br
+	}
+
+	dec := xml.NewDecoder(io.LimitReader(body, maxSitemapSize))
+	dec.CharsetReader = charset.NewReaderLabel
+	dec.Strict = false
+	if err := dec.Decode(v); err != nil {
+		return fmt.Errorf("parsing XML: %w", err)
+	}
+	return nil
+}
+
+// dedupeSeeds drops repeated URLs, keeping the first with the latest date.
+func dedupeSeeds(seeds []SeedURL) []SeedURL {
+	index := make(map[string]int, len(seeds))
+	deduped := seeds[:0]
+	for _, seed := range seeds {
+		if i, ok := index[seed.URL]; ok {
+			if seed.LastMod.After(deduped[i].LastMod) {
+				deduped[i].LastMod = seed.LastMod
+			}
+			continue
+		}
+		index[seed.URL] = len(deduped)
+		deduped = append(deduped, seed)
+	}
+	return deduped
+}
+
+// w3cDateLayouts are the forms of the W3C datetime format used by sitemaps, Atom and dc:date.
+var w3cDateLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// parseW3CDate parses a W3C datetime, returning the zero time if it is missing or invalid.
+func parseW3CDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range w3cDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// rssDateLayouts are the forms of RFC 822 dates found in RSS feeds, which often leave out
+// the weekday or use two-digit years and named zones.
+var rssDateLayouts = []string{
+	time.RFC1123Z,
+	time.RFC1123,
+	"Mon, 2 Jan 2006 15:04:05 -0700",
+	"Mon, 2 Jan 2006 15:04:05 MST",
+	"2 Jan 2006 15:04:05 -0700",
+	"2 Jan 2006 15:04:05 MST",
+	time.RFC822Z,
+	time.RFC822,
+}
+
+// parseRSSDate parses an RSS date, returning the zero time if it is missing or invalid.
+func parseRSSDate(value string) time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range rssDateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t
+		}
+	}
+	return parseW3CDate(value)
+}
@@ -0,0 +1,264 @@
+package scraper_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+// newSitemapSite serves the given documents by path, with "{{host}}" replaced by the server's
+// own address. robots.txt is served if given, and a 404 otherwise. It counts requests by path.
+func newSitemapSite(t *testing.T, docs map[string]string) (*httptest.Server, map[string]*atomic.Int32) {
+	t.Helper()
+
+	requests := make(map[string]*atomic.Int32)
+	for path := range docs {
+		requests[path] = new(atomic.Int32)
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		doc, ok := docs[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		requests[r.URL.Path].Add(1)
+		if strings.HasSuffix(r.URL.Path, ".gz") {
+			w.Write(encode(t, "gzip", []byte(strings.ReplaceAll(doc, "{{host}}", r.Host))))
+			return
+		}
+		w.Write([]byte(strings.ReplaceAll(doc, "{{host}}", r.Host)))
+	}))
+	t.Cleanup(srv.Close)
+	return srv, requests
+}
+
+// seedURLs returns the URLs of seeds, sorted.
+func seedURLs(seeds []scraper.SeedURL) []string {
+	urls := scraper.SeedList(seeds)
+	slices.Sort(urls)
+	return urls
+}
+
+func TestSitemap(t *testing.T) {
+	docs := map[string]string{
+		"/sitemap_index.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<sitemap><loc>http://{{host}}/sitemap-new.xml</loc><lastmod>2024-05-01</lastmod></sitemap>
+	<sitemap><loc>http://{{host}}/sitemap-old.xml.gz</loc><lastmod>2023-01-01T10:00:00+00:00</lastmod></sitemap>
+</sitemapindex>`,
+		"/sitemap-new.xml": `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc> https://example.com/new </loc><lastmod>2024-04-30T08:00:00Z</lastmod></url>
+	<url><loc>https://example.com/undated</loc></url>
+	<url><loc>https://example.com/stale</loc><lastmod>2022-12-31</lastmod></url>
+	<url><loc>https://example.com/shared</loc><lastmod>2024-02-01</lastmod></url>
+</urlset>`,
+		"/sitemap-old.xml.gz": `<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+	<url><loc>https://example.com/archived</loc><lastmod>2022-06-01</lastmod></url>
+	<url><loc>https://example.com/shared</loc><lastmod>2021-01-01</lastmod></url>
+</urlset>`,
+	}
+
+	tests := []struct {
+		name         string
+		opts         scraper.SeedOptions
+		expectURLs   []string
+		expectOldHit int32
+	}{
+		{
+			name: "every sitemap of the index",
+			expectURLs: []string{
+				"https://example.com/archived",
+				"https://example.com/new",
+				"https://example.com/shared",
+				"https://example.com/stale",
+				"https://example.com/undated",
+			},
+			expectOldHit: 1,
+		},
+		{
+			name: "since a date",
+			opts: scraper.SeedOptions{Since: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+			expectURLs: []string{
+				"https://example.com/new",
+				"https://example.com/shared",
+				"https://example.com/undated",
+			},
+			expectOldHit: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newSitemapSite(t, docs)
+
+			seeds, err := newTestScraper(t, 1).Sitemap(context.Background(), srv.URL+"/sitemap_index.xml", tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if urls := seedURLs(seeds); !slices.Equal(urls, tt.expectURLs) {
+				t.Errorf("expected %v, got %v", tt.expectURLs, urls)
+			}
+			if n := requests["/sitemap-old.xml.gz"].Load(); n != tt.expectOldHit {
+				t.Errorf("expected the old sitemap to be fetched %d times, got %d", tt.expectOldHit, n)
+			}
+			for _, seed := range seeds {
+				if seed.URL == "https://example.com/shared" && !seed.LastMod.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("expected a URL listed twice to keep its latest date, got %s", seed.LastMod)
+				}
+			}
+		})
+	}
+}
+
+func TestSiteSeeds(t *testing.T) {
+	urlset := func(path string) string {
+		return fmt.Sprintf(`<urlset><url><loc>https://example.com%s</loc></url></urlset>`, path)
+	}
+
+	tests := []struct {
+		name       string
+		docs       map[string]string
+		expectURLs []string
+		expectErr  bool
+	}{
+		{
+			name: "sitemaps listed in robots.txt",
+			docs: map[string]string{
+				"/robots.txt":  "User-agent: *\nDisallow:\nSitemap: http://{{host}}/a.xml\nSitemap: http://{{host}}/b.xml\n",
+				"/a.xml":       urlset("/a"),
+				"/b.xml":       urlset("/b"),
+				"/sitemap.xml": urlset("/default"),
+			},
+			expectURLs: []string{"https://example.com/a", "https://example.com/b"},
+		},
+		{
+			name: "conventional sitemap without robots.txt",
+			docs: map[string]string{
+				"/sitemap.xml": urlset("/default"),
+			},
+			expectURLs: []string{"https://example.com/default"},
+		},
+		{
+			name: "unreadable sitemap",
+			docs: map[string]string{
+				"/robots.txt": "Sitemap: http://{{host}}/a.xml\nSitemap: http://{{host}}/missing.xml\n",
+				"/a.xml":      urlset("/a"),
+			},
+			expectURLs: []string{"https://example.com/a"},
+			expectErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newSitemapSite(t, tt.docs)
+
+			seeds, err := newTestScraper(t, 1).SiteSeeds(context.Background(), srv.URL, scraper.SeedOptions{})
+			if tt.expectErr != (err != nil) {
+				t.Errorf("expected an error: %v, got %v", tt.expectErr, err)
+			}
+			if urls := seedURLs(seeds); !slices.Equal(urls, tt.expectURLs) {
+				t.Errorf("expected %v, got %v", tt.expectURLs, urls)
+			}
+		})
+	}
+}
+
+func TestFeed(t *testing.T) {
+	tests := []struct {
+		name       string
+		feed       string
+		expectURLs []string
+		expectDate map[string]time.Time
+	}{
+		{
+			name: "rss 2.0",
+			feed: `<rss version="2.0"><channel>
+	<item><link>https://example.com/posts/1</link><pubDate>Tue, 7 May 2024 10:00:00 +0000</pubDate></item>
+	<item><guid>https://example.com/posts/2</guid><pubDate>Mon, 06 May 2024 09:00:00 GMT</pubDate></item>
+	<item><guid isPermaLink="false">tag:example.com,2024:3</guid></item>
+</channel></rss>`,
+			expectURLs: []string{"https://example.com/posts/1", "https://example.com/posts/2"},
+			expectDate: map[string]time.Time{
+				"https://example.com/posts/1": time.Date(2024, 5, 7, 10, 0, 0, 0, time.UTC),
+				"https://example.com/posts/2": time.Date(2024, 5, 6, 9, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "rss 1.0",
+			feed: `<rdf:RDF xmlns:rdf="http://www.w3.org/1999/02/22-rdf-syntax-ns#" xmlns="http://purl.org/rss/1.0/" xmlns:dc="http://purl.org/dc/elements/1.1/">
+	<channel><title>Example</title></channel>
+	<item><link>https://example.com/rdf/1</link><dc:date>2024-05-01T12:00:00Z</dc:date></item>
+</rdf:RDF>`,
+			expectURLs: []string{"https://example.com/rdf/1"},
+			expectDate: map[string]time.Time{
+				"https://example.com/rdf/1": time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			name: "atom",
+			feed: `<feed xmlns="http://www.w3.org/2005/Atom">
+	<entry>
+		<link rel="self" href="/feed/entries/1"/>
+		<link rel="alternate" href="/articles/1"/>
+		<updated>2024-05-02T08:00:00Z</updated>
+		<published>2024-04-01T08:00:00Z</published>
+	</entry>
+	<entry><link href="https://other.example/2"/><published>2024-04-02T08:00:00Z</published></entry>
+</feed>`,
+			expectURLs: []string{"http://{{host}}/articles/1", "https://other.example/2"},
+			expectDate: map[string]time.Time{
+				"http://{{host}}/articles/1": time.Date(2024, 5, 2, 8, 0, 0, 0, time.UTC),
+				"https://other.example/2":    time.Date(2024, 4, 2, 8, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, _ := newSitemapSite(t, map[string]string{"/feed.xml": tt.feed})
+			host := strings.TrimPrefix(srv.URL, "http://")
+
+			seeds, err := newTestScraper(t, 1).Feed(context.Background(), srv.URL+"/feed.xml", scraper.SeedOptions{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var expectURLs []string
+			for _, u := range tt.expectURLs {
+				expectURLs = append(expectURLs, strings.ReplaceAll(u, "{{host}}", host))
+			}
+			slices.Sort(expectURLs)
+			if urls := seedURLs(seeds); !slices.Equal(urls, expectURLs) {
+				t.Errorf("expected %v, got %v", expectURLs, urls)
+			}
+
+			dates := make(map[string]time.Time, len(seeds))
+			for _, seed := range seeds {
+				dates[seed.URL] = seed.LastMod
+			}
+			for u, date := range tt.expectDate {
+				u = strings.ReplaceAll(u, "{{host}}", host)
+				if !dates[u].Equal(date) {
+					t.Errorf("%s: expected date %s, got %s", u, date, dates[u])
+				}
+			}
+		})
+	}
+}
+
+func TestFeedNotFound(t *testing.T) {
+	srv, _ := newSitemapSite(t, nil)
+
+	if _, err := newTestScraper(t, 1).Feed(context.Background(), srv.URL+"/feed.xml", scraper.SeedOptions{}); err == nil {
+		t.Error("expected an error for a missing feed")
+	}
+}
@@ -0,0 +1,225 @@
+package scraper
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"time"
+)
+
+// latencySamples is the number of request latencies kept for computing percentiles.
+// Beyond it, latencies are sampled so that every request has the same chance of being kept.
+const latencySamples = 10000
+
+// Stats is a snapshot of the progress of a scraper, counted over its current run or, between runs,
+// over the last one. Runs overlapping each other, e.g. a crawl started while a scrape is going on,
+// are counted together.
+type Stats struct {
+	Queued    int64 // URLs waiting for a worker
+	InFlight  int64 // URLs being scraped
+	Succeeded int64 // URLs scraped without an error and with a status below 400
+	Failed    int64 // URLs which ended with an error or a status of 400 or above
+	Bytes     int64 // response bodies read from the network, before decoding; cached responses are not counted
+
+	// StatusCodes counts the URLs done by the status of their final response.
+	StatusCodes map[int]int64
+	// Latency percentiles of every request made, including retries.
+	LatencyP50 time.Duration
+	LatencyP90 time.Duration
+	LatencyP99 time.Duration
+	LatencyMax time.Duration
+	// Elapsed is the time since the run started, up to when it ended if it is over.
+	Elapsed time.Duration
+}
+
+// Done returns the number of URLs scraped, successfully or not.
+func (st Stats) Done() int64 {
+	return st.Succeeded + st.Failed
+}
+
+// Throughput returns the number of URLs scraped per second.
+func (st Stats) Throughput() float64 {
+	if st.Elapsed <= 0 {
+		return 0
+	}
+	return float64(st.Done()) / st.Elapsed.Seconds()
+}
+
+// Stats returns the progress of the scraper.
+func (s *RateLimitedScraper) Stats() Stats {
+	return s.stats.snapshot(true)
+}
+
+// Sets a function called with the progress of the scraper every time a URL is done, e.g. to
+// draw a progress bar. Calls are made one at a time from the workers, so fn should return quickly.
+// The latency percentiles are left out of the Stats it is given, as they are costly to compute
+// that often; LatencyMax is set, and Stats returns the rest.
+func (s *RateLimitedScraper) SetProgressFunc(fn func(Stats)) {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+
+	s.stats.progress = fn
+}
+
+// scrapeStats counts the progress of a scraper.
+type scrapeStats struct {
+	mu          sync.Mutex
+	runs        int // runs going on
+	started     time.Time
+	ended       time.Time // zero while a run is going on
+	queued      int64
+	inFlight    int64
+	succeeded   int64
+	failed      int64
+	bytes       int64
+	statusCodes map[int]int64
+	latencies   []time.Duration
+	requests    int64 // requests made, of which latencies holds a sample
+	maxLatency  time.Duration
+	progress    func(Stats)
+
+	progressMu sync.Mutex // serialises calls to progress
+}
+
+// begin starts counting a run. A run starting when no other is going on resets the counts.
+func (st *scrapeStats) begin() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.runs == 0 {
+		st.queued, st.inFlight, st.succeeded, st.failed, st.bytes = 0, 0, 0, 0, 0
+		st.statusCodes = nil
+		st.latencies = nil
+		st.requests = 0
+		st.maxLatency = 0
+		st.started = time.Now()
+		st.ended = time.Time{}
+	}
+	st.runs++
+}
+
+// end counts a run as over, stopping the clock once no other run is going on.
+func (st *scrapeStats) end() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.runs--
+	if st.runs == 0 {
+		st.ended = time.Now()
+	}
+}
+
+// queue counts n URLs added to the queue.
+func (st *scrapeStats) queue(n int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.queued += int64(n)
+}
+
+// drop counts n queued URLs given up on before a worker took them, because the context is done.
+func (st *scrapeStats) drop(n int) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.queued -= int64(n)
+}
+
+// start counts a queued URL taken by a worker.
+func (st *scrapeStats) start() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.queued--
+	st.inFlight++
+}
+
+// abandon counts a URL given up on without a result, because the context is done.
+func (st *scrapeStats) abandon() {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.inFlight--
+}
+
+// request records the latency of one request.
+func (st *scrapeStats) request(latency time.Duration) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	st.requests++
+	st.maxLatency = max(st.maxLatency, latency)
+	if len(st.latencies) < latencySamples {
+		st.latencies = append(st.latencies, latency)
+	} else if i := rand.Int64N(st.requests); i < latencySamples {
+		st.latencies[i] = latency
+	}
+}
+
+// finish counts the result of a URL and reports the progress.
+func (st *scrapeStats) finish(result Result) {
+	st.mu.Lock()
+	st.inFlight--
+	if result.Error != nil || result.StatusCode >= 400 {
+		st.failed++
+	} else {
+		st.succeeded++
+	}
+	st.bytes += result.received
+	if result.StatusCode != 0 {
+		if st.statusCodes == nil {
+			st.statusCodes = make(map[int]int64)
+		}
+		st.statusCodes[result.StatusCode]++
+	}
+	progress := st.progress
+	st.mu.Unlock()
+
+	if progress != nil {
+		st.progressMu.Lock()
+		defer st.progressMu.Unlock()
+
+		progress(st.snapshot(false))
+	}
+}
+
+// snapshot returns the current counts, with the latency percentiles if percentiles is set.
+func (st *scrapeStats) snapshot(percentiles bool) Stats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	stats := Stats{
+		Queued:      st.queued,
+		InFlight:    st.inFlight,
+		Succeeded:   st.succeeded,
+		Failed:      st.failed,
+		Bytes:       st.bytes,
+		StatusCodes: make(map[int]int64, len(st.statusCodes)),
+		LatencyMax:  st.maxLatency,
+	}
+	for code, n := range st.statusCodes {
+		stats.StatusCodes[code] = n
+	}
+	switch {
+	case !st.ended.IsZero():
+		stats.Elapsed = st.ended.Sub(st.started)
+	case !st.started.IsZero():
+		stats.Elapsed = time.Since(st.started)
+	}
+
+	if percentiles && len(st.latencies) > 0 {
+		sorted := slices.Clone(st.latencies)
+		slices.Sort(sorted)
+		stats.LatencyP50 = percentile(sorted, 0.50)
+		stats.LatencyP90 = percentile(sorted, 0.90)
+		stats.LatencyP99 = percentile(sorted, 0.99)
+	}
+	return stats
+}
+
+// percentile returns the p-th percentile of sorted latencies, by the nearest-rank method.
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+	return sorted[min(max(rank, 0), len(sorted)-1)]
+}
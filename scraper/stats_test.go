@@ -0,0 +1,221 @@
+package scraper_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hiteshrepo/awesome-tools/scraper"
+)
+
+func TestStats(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 2)
+	results, err := s.ScrapeURLs(context.Background(), []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/missing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, results)
+
+	st := s.Stats()
+	if st.Succeeded != 2 || st.Failed != 1 || st.Done() != 3 {
+		t.Errorf("expected 2 succeeded and 1 failed, got %+v", st)
+	}
+	if st.Queued != 0 || st.InFlight != 0 {
+		t.Errorf("expected nothing queued or in flight, got %d and %d", st.Queued, st.InFlight)
+	}
+	if st.StatusCodes[http.StatusOK] != 2 || st.StatusCodes[http.StatusNotFound] != 1 {
+		t.Errorf("expected the counts per status, got %v", st.StatusCodes)
+	}
+	if st.Bytes < int64(2*len("hello")) {
+		t.Errorf("expected the content received to be counted, got %d bytes", st.Bytes)
+	}
+	if st.LatencyP50 <= 0 || st.LatencyP50 > st.LatencyP99 || st.LatencyP99 > st.LatencyMax {
+		t.Errorf("expected ordered latency percentiles, got p50 %s, p99 %s, max %s", st.LatencyP50, st.LatencyP99, st.LatencyMax)
+	}
+	if st.Throughput() <= 0 {
+		t.Errorf("expected a throughput, got %f", st.Throughput())
+	}
+}
+
+func TestStatsPercentiles(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var urls []string
+	for i := range 9 {
+		urls = append(urls, fmt.Sprintf("%s/%d", srv.URL, i))
+	}
+	s := newTestScraper(t, 2)
+	results, err := s.ScrapeURLs(context.Background(), urls)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, results)
+
+	// by nearest rank, the 90th percentile of 9 latencies is the 9th, i.e. the slowest
+	st := s.Stats()
+	if st.LatencyP90 != st.LatencyMax || st.LatencyP99 != st.LatencyMax {
+		t.Errorf("expected p90 and p99 to be the maximum %s, got %s and %s", st.LatencyMax, st.LatencyP90, st.LatencyP99)
+	}
+}
+
+func TestStatsBytes(t *testing.T) {
+	gzipped := encode(t, "gzip", []byte(strings.Repeat("hello ", 1000)))
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(gzipped)
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 1)
+	if result := scrapeOne(t, s, srv.URL); result.Error != nil {
+		t.Fatal(result.Error)
+	}
+	// the bytes received are counted, not those of the decoded content
+	if st := s.Stats(); st.Bytes != int64(len(gzipped)) {
+		t.Errorf("expected the %d bytes sent to be counted, got %d", len(gzipped), st.Bytes)
+	}
+}
+
+func TestStatsPerRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	s := newTestScraper(t, 2)
+	for run := 1; run <= 2; run++ {
+		results, err := s.ScrapeURLs(context.Background(), []string{srv.URL + "/a", srv.URL + "/b"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		collect(t, results)
+
+		// once the run is over, the clock stops
+		st := s.Stats()
+		for start := time.Now(); !time.Now().After(start); {
+		}
+		if later := s.Stats(); later.Elapsed != st.Elapsed {
+			t.Errorf("run %d: expected the elapsed time to stop at %s, got %s", run, st.Elapsed, later.Elapsed)
+		}
+		if st.Done() != 2 {
+			t.Errorf("run %d: expected the URLs of this run only, got %d done", run, st.Done())
+		}
+	}
+}
+
+func TestStatsCancelled(t *testing.T) {
+	pages := make(map[string][]string)
+	for i := range 20 {
+		pages["/"] = append(pages["/"], fmt.Sprintf("/%d", i))
+	}
+
+	tests := []struct {
+		name  string
+		start func(ctx context.Context, s *scraper.RateLimitedScraper, srvURL string) (<-chan scraper.Result, error)
+	}{
+		{
+			name: "scrape",
+			start: func(ctx context.Context, s *scraper.RateLimitedScraper, srvURL string) (<-chan scraper.Result, error) {
+				var urls []string
+				for _, path := range pages["/"] {
+					urls = append(urls, srvURL+path)
+				}
+				return s.ScrapeURLs(ctx, urls)
+			},
+		},
+		{
+			name: "stream",
+			start: func(ctx context.Context, s *scraper.RateLimitedScraper, srvURL string) (<-chan scraper.Result, error) {
+				var urls []string
+				for _, path := range pages["/"] {
+					urls = append(urls, srvURL+path)
+				}
+				return s.ScrapeSeq(ctx, slices.Values(urls))
+			},
+		},
+		{
+			name: "crawl",
+			start: func(ctx context.Context, s *scraper.RateLimitedScraper, srvURL string) (<-chan scraper.Result, error) {
+				return s.Crawl(ctx, []string{srvURL + "/"}, scraper.CrawlOptions{MaxDepth: 1})
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			site := newTestSite(t, pages)
+			// after the seed page, every request hangs until it is cancelled
+			var once sync.Once
+			hung := make(chan struct{})
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/" {
+					site.Config.Handler.ServeHTTP(w, r)
+					return
+				}
+				once.Do(func() { close(hung) })
+				<-r.Context().Done()
+			}))
+			defer srv.Close()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s := newTestScraper(t, 2)
+			results, err := tt.start(ctx, s, srv.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			<-hung
+			cancel()
+			collect(t, results)
+
+			if st := s.Stats(); st.Queued != 0 || st.InFlight != 0 {
+				t.Errorf("expected nothing left queued or in flight, got %d and %d", st.Queued, st.InFlight)
+			}
+		})
+	}
+}
+
+func TestProgressFunc(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	var done []int64
+	s := newTestScraper(t, 2)
+	s.SetProgressFunc(func(st scraper.Stats) {
+		done = append(done, st.Done())
+		if st.LatencyP50 != 0 || st.LatencyMax <= 0 {
+			t.Errorf("expected only the maximum latency, got p50 %s and max %s", st.LatencyP50, st.LatencyMax)
+		}
+	})
+
+	results, err := s.ScrapeURLs(context.Background(), []string{srv.URL + "/a", srv.URL + "/b", srv.URL + "/c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	collect(t, results)
+
+	if !slices.Equal(done, []int64{1, 2, 3}) {
+		t.Errorf("expected a call per URL done, got %v", done)
+	}
+}